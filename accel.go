@@ -0,0 +1,78 @@
+package goqemu
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+type vmAccel string
+
+const (
+	AccelAuto vmAccel = "auto" // KVM si /dev/kvm es accesible, de lo contrario TCG
+	AccelKVM  vmAccel = "kvm"  // virtualización por hardware, falla si no está disponible
+	AccelTCG  vmAccel = "tcg"  // emulación por software multihilo
+)
+
+// kvmDevice es el dispositivo de KVM, variable para poder sustituirlo en pruebas
+var kvmDevice = "/dev/kvm"
+
+// checkKVM verifica que KVM se pueda usar: Linux y /dev/kvm accesible
+// para lectura y escritura por el usuario actual
+func checkKVM() error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("KVM solo está disponible en Linux, sistema actual: %s", runtime.GOOS)
+	}
+
+	f, err := os.OpenFile(kvmDevice, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("%s no accesible: %v", kvmDevice, err)
+	}
+	f.Close()
+
+	return nil
+}
+
+// selectAccel resuelve el acelerador a usar según lo solicitado, lo que
+// soporta el binario de QEMU y el acceso a /dev/kvm
+func selectAccel(requested vmAccel, caps *Capabilities) (vmAccel, error) {
+	kvmSupported := caps == nil || len(caps.Accelerators) == 0 || caps.HasAccelerator("kvm")
+
+	switch requested {
+	case AccelKVM:
+		if !kvmSupported {
+			return "", fmt.Errorf("el binario de QEMU no soporta KVM (aceleradores: %v)", caps.Accelerators)
+		}
+		if err := checkKVM(); err != nil {
+			return "", fmt.Errorf("acelerador KVM no disponible: %v", err)
+		}
+		return AccelKVM, nil
+
+	case AccelTCG:
+		return AccelTCG, nil
+
+	case AccelAuto, "":
+		if kvmSupported && checkKVM() == nil {
+			return AccelKVM, nil
+		}
+		return AccelTCG, nil
+
+	default:
+		return "", fmt.Errorf("acelerador desconocido %q, use auto, kvm o tcg", requested)
+	}
+}
+
+// accelArgs devuelve los argumentos de QEMU para el acelerador
+func accelArgs(accel vmAccel) []string {
+	if accel == AccelKVM {
+		return []string{"-accel", "kvm"}
+	}
+	// TCG multihilo usa un hilo del host por CPU virtual
+	return []string{"-accel", "tcg,thread=multi"}
+}
+
+// Accel devuelve el acelerador elegido para la VM. Con TCG la VM es
+// bastante más lenta y conviene ampliar los timeouts de las pruebas.
+func (vm *QemuVM) Accel() vmAccel {
+	return vm.accel
+}
@@ -0,0 +1,54 @@
+package goqemu
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSelectAccel(t *testing.T) {
+	caps, err := ProbeCapabilities(NewFakeRunner(), "qemu-system-x86_64")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := kvmDevice
+	defer func() { kvmDevice = original }()
+
+	// Sin /dev/kvm: auto usa TCG y kvm explícito falla
+	kvmDevice = filepath.Join(t.TempDir(), "no-existe")
+
+	accel, err := selectAccel(AccelAuto, caps)
+	if err != nil || accel != AccelTCG {
+		t.Errorf("auto sin KVM: esperado %s, obtenido %s (%v)", AccelTCG, accel, err)
+	}
+	if _, err := selectAccel(AccelKVM, caps); err == nil {
+		t.Error("kvm sin /dev/kvm debería fallar")
+	}
+	if _, err := selectAccel("hvf", caps); err == nil {
+		t.Error("un acelerador desconocido debería fallar")
+	}
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	// Con un dispositivo accesible auto elige KVM
+	kvmDevice = filepath.Join(t.TempDir(), "kvm")
+	if err := os.WriteFile(kvmDevice, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	accel, err = selectAccel("", caps)
+	if err != nil || accel != AccelKVM {
+		t.Errorf("auto con KVM: esperado %s, obtenido %s (%v)", AccelKVM, accel, err)
+	}
+
+	// Si el binario no soporta KVM se usa TCG aunque exista /dev/kvm
+	caps.Accelerators = []string{"tcg"}
+	accel, err = selectAccel(AccelAuto, caps)
+	if err != nil || accel != AccelTCG {
+		t.Errorf("auto con binario sin KVM: esperado %s, obtenido %s (%v)", AccelTCG, accel, err)
+	}
+}
@@ -0,0 +1,158 @@
+package goqemu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// vmNameRe valida los nombres de VM: se usan como nombre de directorio
+var vmNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// ErrAttached se devuelve al intentar detener una VM obtenida con Attach,
+// cuyo proceso QEMU pertenece a otro programa
+var ErrAttached = errors.New("la VM pertenece a otro proceso, use Detach")
+
+// sessionInfo es la información que necesita otro proceso para conectarse
+// por SSH a una VM en ejecución. Se guarda en session.json en su directorio.
+type sessionInfo struct {
+	Pid     int    `json:"pid"`      // pid del proceso QEMU
+	SSHPort int    `json:"ssh_port"` // puerto del host reenviado al 22 del invitado
+	SSHUser string `json:"ssh_user"`
+	SSHKey  string `json:"ssh_key"` // ruta de la clave privada
+
+	Ports []PortForward `json:"ports,omitempty"` // reenvíos de puertos activos
+}
+
+// sessionFilePath devuelve la ruta de session.json de la VM
+func (vm *QemuVM) sessionFilePath() string {
+	return filepath.Join(vm.workDir, "session.json")
+}
+
+// writeSession publica la información de conexión de la VM en ejecución
+func (vm *QemuVM) writeSession() error {
+	info := sessionInfo{
+		SSHPort: vm.sshPort,
+		SSHUser: vm.sshUser(),
+		SSHKey:  vm.sshKeyPath(),
+		Ports:   vm.PortForwards(),
+	}
+	vm.mu.Lock()
+	if vm.proc != nil {
+		info.Pid = vm.proc.Pid()
+	}
+	vm.mu.Unlock()
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(vm.sessionFilePath(), data, 0600)
+}
+
+// updateSession vuelve a publicar la sesión tras cambiar los reenvíos de
+// puertos. Es informativa para Attach: un fallo no afecta a la VM.
+func (vm *QemuVM) updateSession() {
+	if vm.attached {
+		return
+	}
+	vm.writeSession()
+}
+
+// readSession lee la información de conexión de la VM con directorio dir.
+// Devuelve un error si no existe o su proceso QEMU ya terminó.
+func readSession(dir string) (*sessionInfo, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "session.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var info sessionInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	if info.Pid <= 0 || !processAlive(info.Pid) {
+		return nil, fmt.Errorf("el proceso QEMU %d ya terminó", info.Pid)
+	}
+	return &info, nil
+}
+
+// RunningVMs devuelve los nombres de las VMs en ejecución a las que se
+// puede conectar con Attach
+func RunningVMs() ([]string, error) {
+	dir := filepath.Join(os.Getenv("HOME"), "qemu", "vms")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := readSession(filepath.Join(dir, e.Name())); err == nil {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// Attach se conecta por SSH a una VM en ejecución lanzada por otro proceso,
+// identificada por su nombre (Name) o id. La VM devuelta permite ejecutar
+// comandos, copiar archivos y abrir una shell; al terminar se llama a Detach.
+func Attach(name string) (*QemuVM, error) {
+	if !vmNameRe.MatchString(name) {
+		return nil, fmt.Errorf("nombre de VM inválido: %q", name)
+	}
+
+	dir := getVMDir(name)
+	info, err := readSession(dir)
+	if err != nil {
+		return nil, fmt.Errorf("la VM %q no está en ejecución: %v", name, err)
+	}
+
+	vm := &QemuVM{
+		config: &QemuConfig{
+			SSHUser:    info.SSHUser,
+			SSHKeyPath: info.SSHKey,
+		},
+		id:       name,
+		workDir:  dir,
+		sshPort:  info.SSHPort,
+		ports:    append([]PortForward{}, info.Ports...),
+		state:    StateRunning,
+		attached: true,
+	}
+	vm.ctx, vm.cancel = context.WithCancel(context.Background())
+
+	if err := vm.connectSSH(); err != nil {
+		vm.cancel()
+		return nil, fmt.Errorf("error conectando SSH con la VM %q: %v", name, err)
+	}
+	vm.startQueue()
+
+	return vm, nil
+}
+
+// Detach cierra la conexión de una VM obtenida con Attach sin detenerla
+func (vm *QemuVM) Detach() error {
+	if !vm.attached {
+		return errors.New("la VM no se obtuvo con Attach")
+	}
+
+	vm.stopQueue()
+	vm.closeForwards()
+	vm.closeSSH()
+	vm.closeConsole()
+	vm.closeGuestAgent()
+	vm.cancel()
+	return nil
+}
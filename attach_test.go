@@ -0,0 +1,67 @@
+package goqemu
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAttachAndShell(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	srv := newFakeSSHServer(t)
+	_, portStr, _ := net.SplitHostPort(srv.addr)
+	port, _ := strconv.Atoi(portStr)
+
+	// VM "lanzada" por otro proceso: session.json apunta al servidor de prueba
+	owner := &QemuVM{config: &QemuConfig{}, id: "web"}
+	owner.workDir, _ = createVMDir("web")
+	owner.sshPort = port
+	owner.proc = &FakeProcess{pid: os.Getpid()}
+	signer, err := owner.loadSSHKey()
+	if err != nil {
+		t.Fatalf("Error generando clave: %v", err)
+	}
+	srv.authorize(signer.PublicKey())
+	if err := owner.writeSession(); err != nil {
+		t.Fatalf("Error guardando sesión: %v", err)
+	}
+
+	names, err := RunningVMs()
+	if err != nil || len(names) != 1 || names[0] != "web" {
+		t.Fatalf("VMs en ejecución inesperadas: %v, %v", names, err)
+	}
+
+	vm, err := Attach("web")
+	if err != nil {
+		t.Fatalf("Error en Attach: %v", err)
+	}
+	defer vm.Detach()
+
+	if cmd := vm.SendCommand("echo ok"); cmd.Stdout != "ok\n" {
+		t.Errorf("Comando en la VM adjunta: %q, %v", cmd.Stdout, cmd.Err)
+	}
+
+	// Una VM adjunta no se puede detener
+	if _, err := vm.Shutdown(0); !errors.Is(err, ErrAttached) {
+		t.Errorf("Se esperaba ErrAttached, obtenido: %v", err)
+	}
+
+	// Shell sin terminal: la entrada se envía tal cual
+	var stdout, stderr bytes.Buffer
+	err = vm.Shell(strings.NewReader("echo hola\nexit 3\n"), &stdout, &stderr)
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus != 3 {
+		t.Errorf("Se esperaba código de salida 3, obtenido: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "hola") {
+		t.Errorf("Salida de la shell: %q", stdout.String())
+	}
+
+	if _, err := Attach("no-existe"); err == nil {
+		t.Error("Attach a una VM inexistente debería fallar")
+	}
+}
@@ -0,0 +1,250 @@
+package goqemu
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Version es una versión semver de QEMU
+type Version struct {
+	Major, Minor, Patch int
+}
+
+var versionRe = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ParseVersion interpreta versiones como "8.2.1" o "10.0"
+func ParseVersion(s string) (Version, error) {
+	m := versionRe.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("versión inválida: %q", s)
+	}
+
+	var v Version
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+	return v, nil
+}
+
+// Compare devuelve -1, 0 o 1 si v es menor, igual o mayor que o
+func (v Version) Compare(o Version) int {
+	a := [3]int{v.Major, v.Minor, v.Patch}
+	b := [3]int{o.Major, o.Minor, o.Patch}
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Capabilities describe lo que soporta el binario de QEMU instalado
+type Capabilities struct {
+	Binary       string   // ruta del binario sondeado
+	Version      Version  // versión de QEMU
+	Machines     []string // tipos de máquina (-machine help)
+	Accelerators []string // aceleradores (-accel help)
+	Displays     []string // backends de pantalla (-display help)
+	Devices      []string // dispositivos y alias (-device help)
+	NICModels    []string // dispositivos de red (sección "Network devices")
+}
+
+// HasMachine indica si el tipo de máquina está soportado
+func (c *Capabilities) HasMachine(name string) bool { return contains(c.Machines, name) }
+
+// HasAccelerator indica si el acelerador está soportado
+func (c *Capabilities) HasAccelerator(name string) bool { return contains(c.Accelerators, name) }
+
+// HasDisplay indica si el backend de pantalla está soportado
+func (c *Capabilities) HasDisplay(name string) bool { return contains(c.Displays, name) }
+
+// HasDevice indica si el dispositivo está soportado
+func (c *Capabilities) HasDevice(name string) bool { return contains(c.Devices, name) }
+
+// HasNICModel indica si el modelo de tarjeta de red está soportado
+func (c *Capabilities) HasNICModel(name string) bool { return contains(c.NICModels, name) }
+
+func contains(list []string, name string) bool {
+	for _, s := range list {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// capsCache guarda las capacidades sondeadas por binario real. La clave
+// incluye tamaño y fecha de modificación para detectar actualizaciones.
+var capsCache = struct {
+	sync.Mutex
+	m map[string]*Capabilities
+}{m: make(map[string]*Capabilities)}
+
+// ProbeCapabilities consulta al binario de QEMU su versión, máquinas,
+// aceleradores, pantallas y dispositivos soportados. Con ExecRunner el
+// resultado se guarda en caché por binario.
+func ProbeCapabilities(runner Runner, binary string) (*Capabilities, error) {
+	path, err := runner.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("%s no está instalado: %v", binary, err)
+	}
+
+	var cacheKey string
+	if _, ok := runner.(ExecRunner); ok {
+		if info, err := os.Stat(path); err == nil {
+			cacheKey = fmt.Sprintf("%s|%d|%d", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	if cacheKey != "" {
+		capsCache.Lock()
+		caps, ok := capsCache.m[cacheKey]
+		capsCache.Unlock()
+		if ok {
+			return caps, nil
+		}
+	}
+
+	caps, err := probeCapabilities(runner, binary)
+	if err != nil {
+		return nil, err
+	}
+	caps.Binary = path
+
+	if cacheKey != "" {
+		capsCache.Lock()
+		capsCache.m[cacheKey] = caps
+		capsCache.Unlock()
+	}
+
+	return caps, nil
+}
+
+func probeCapabilities(runner Runner, binary string) (*Capabilities, error) {
+	caps := &Capabilities{}
+
+	out, err := runner.Output(binary, "--version")
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo versión de QEMU: %v", err)
+	}
+	m := regexp.MustCompile(`QEMU emulator version (\S+)`).FindStringSubmatch(string(out))
+	if m == nil {
+		return nil, errors.New("no se pudo determinar la versión de QEMU")
+	}
+	caps.Version, err = ParseVersion(m[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if out, err = runner.Output(binary, "-machine", "help"); err != nil {
+		return nil, fmt.Errorf("error consultando máquinas de QEMU: %v", err)
+	}
+	caps.Machines = parseHelpList(out)
+
+	if out, err = runner.Output(binary, "-accel", "help"); err != nil {
+		return nil, fmt.Errorf("error consultando aceleradores de QEMU: %v", err)
+	}
+	caps.Accelerators = parseHelpList(out)
+
+	if out, err = runner.Output(binary, "-display", "help"); err != nil {
+		return nil, fmt.Errorf("error consultando pantallas de QEMU: %v", err)
+	}
+	caps.Displays = parseHelpList(out)
+
+	if out, err = runner.Output(binary, "-device", "help"); err != nil {
+		return nil, fmt.Errorf("error consultando dispositivos de QEMU: %v", err)
+	}
+	caps.Devices, caps.NICModels = parseDeviceHelp(out)
+
+	return caps, nil
+}
+
+// parseHelpList interpreta la salida de "-machine help", "-accel help" y
+// "-display help": una línea de cabecera terminada en ":" y luego un
+// elemento por línea cuyo nombre es la primera palabra
+func parseHelpList(out []byte) []string {
+	var items []string
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		items = append(items, strings.Fields(line)[0])
+	}
+	return items
+}
+
+var deviceNameRe = regexp.MustCompile(`^name "([^"]+)"(?:.*alias "([^"]+)")?`)
+
+// parseDeviceHelp interpreta la salida de "-device help" y devuelve todos
+// los dispositivos (con sus alias) y los de la sección de red
+func parseDeviceHelp(out []byte) (devices, nics []string) {
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, ":") {
+			section = strings.TrimSuffix(line, ":")
+			continue
+		}
+
+		m := deviceNameRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		names := []string{m[1]}
+		if m[2] != "" {
+			names = append(names, m[2])
+		}
+		devices = append(devices, names...)
+		if section == "Network devices" {
+			nics = append(nics, names...)
+		}
+	}
+	return devices, nics
+}
+
+// validateCapabilities comprueba la configuración contra las capacidades del
+// binario antes de lanzar QEMU. Las listas vacías se consideran desconocidas.
+func validateCapabilities(config *QemuConfig, caps *Capabilities, errs *ConfigError) {
+	if config.Machine != "" && len(caps.Machines) > 0 && !caps.HasMachine(config.Machine) {
+		errs.add("Machine", "tipo de máquina %q no soportado por QEMU %s (disponibles: %s)",
+			config.Machine, caps.Version, strings.Join(caps.Machines, ", "))
+	}
+
+	switch config.Display {
+	case DisplayGTK, DisplaySDL:
+		if len(caps.Displays) > 0 && !caps.HasDisplay(string(config.Display)) {
+			errs.add("Display", "display %q no soportado por QEMU %s (disponibles: %s)",
+				config.Display, caps.Version, strings.Join(caps.Displays, ", "))
+		}
+	}
+
+	if config.GuestAgent && len(caps.Devices) > 0 && !caps.HasDevice("virtserialport") {
+		errs.add("GuestAgent", "QEMU %s no soporta virtserialport, necesario para el guest agent", caps.Version)
+	}
+
+	for i, n := range config.NICs {
+		if len(caps.NICModels) > 0 && !caps.HasNICModel(n.Model) {
+			errs.add(fmt.Sprintf("NICs[%d]", i), "modelo de red %q no soportado por QEMU %s (disponibles: %s)",
+				n.Model, caps.Version, strings.Join(caps.NICModels, ", "))
+		}
+	}
+}
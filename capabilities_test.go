@@ -0,0 +1,74 @@
+package goqemu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		v1, v2 string
+		want   int
+	}{
+		{"10.0.0", "8.1.0", 1},
+		{"8.1.0", "8.1.0", 0},
+		{"8.0.5", "8.1.0", -1},
+		{"8.10.0", "8.9.1", 1},
+		{"9.2", "9.2.0", 0},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.v1, tt.v2); got != tt.want {
+			t.Errorf("compareVersions(%s, %s) = %d, esperado %d", tt.v1, tt.v2, got, tt.want)
+		}
+	}
+}
+
+func TestProbeCapabilities(t *testing.T) {
+	runner := NewFakeRunner()
+	runner.SetOutput("qemu-system-x86_64 --version", "QEMU emulator version 10.0.2 (Debian 1:10.0.2+ds-1)\n", nil)
+
+	caps, err := ProbeCapabilities(runner, "qemu-system-x86_64")
+	if err != nil {
+		t.Fatalf("Error sondeando capacidades: %v", err)
+	}
+
+	if caps.Version != (Version{10, 0, 2}) {
+		t.Errorf("Versión incorrecta: %s", caps.Version)
+	}
+	if !caps.HasMachine("q35") || caps.HasMachine("Supported") {
+		t.Errorf("Máquinas incorrectas: %v", caps.Machines)
+	}
+	if !caps.HasAccelerator("kvm") || !caps.HasDisplay("gtk") {
+		t.Errorf("Aceleradores o pantallas incorrectos: %v %v", caps.Accelerators, caps.Displays)
+	}
+	if !caps.HasNICModel("virtio-net") || caps.HasNICModel("virtio-blk") {
+		t.Errorf("Modelos de red incorrectos: %v", caps.NICModels)
+	}
+	if !caps.HasDevice("virtserialport") {
+		t.Errorf("Dispositivos incorrectos: %v", caps.Devices)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	caps, err := ProbeCapabilities(NewFakeRunner(), "qemu-system-x86_64")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := &ConfigError{}
+	validateCapabilities(&QemuConfig{Display: DisplayGTK, Machine: "q35"}, caps, errs)
+	if err := errs.errOrNil(); err != nil {
+		t.Errorf("Configuración válida rechazada: %v", err)
+	}
+
+	caps.Displays = []string{"none", "curses"}
+	errs = &ConfigError{}
+	validateCapabilities(&QemuConfig{Display: DisplaySDL, Machine: "virt"}, caps, errs)
+	if len(errs.Problems) != 2 {
+		t.Fatalf("Se esperaban 2 problemas, obtenidos: %v", errs)
+	}
+	if !strings.Contains(errs.Error(), `"virt"`) || !strings.Contains(errs.Error(), `"sdl"`) {
+		t.Errorf("Mensaje de error impreciso: %v", errs)
+	}
+}
@@ -0,0 +1,60 @@
+// Comando shell: abre una shell interactiva en una VM de goqemu en ejecución.
+//
+// Uso:
+//
+//	go run ./cmd/shell <nombre>
+//
+// Sin argumentos lista las VMs en ejecución.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cdvelop/goqemu"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "uso: shell <nombre>")
+		listVMs()
+		os.Exit(2)
+	}
+
+	vm, err := goqemu.Attach(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		listVMs()
+		os.Exit(1)
+	}
+
+	err = vm.Shell(os.Stdin, os.Stdout, os.Stderr)
+	vm.Detach()
+
+	var exitErr *goqemu.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitStatus)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// listVMs muestra las VMs a las que se puede conectar
+func listVMs() {
+	names, err := goqemu.RunningVMs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error listando VMs: %v\n", err)
+		return
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(os.Stderr, "no hay VMs en ejecución")
+		return
+	}
+	fmt.Fprintln(os.Stderr, "VMs en ejecución:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", name)
+	}
+}
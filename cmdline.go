@@ -0,0 +1,53 @@
+package goqemu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// baseArgs genera los argumentos de QEMU comunes a todos los arranques:
+// memoria, CPUs, disco, máquina y acelerador. La red la añade netArgs en
+// cada arranque porque depende del puerto SSH reservado, y la pantalla
+// displayArgs porque OpenWindow arranca con ventana.
+func baseArgs(config *QemuConfig, diskPath string, accel vmAccel) []string {
+	args := []string{
+		"-m", fmt.Sprintf("%dG", config.RAM),
+		"-smp", fmt.Sprintf("%d", config.CPU),
+		"-hda", diskPath,
+	}
+
+	if config.Machine != "" {
+		args = append(args, "-machine", config.Machine)
+	}
+	args = append(args, accelArgs(accel)...)
+
+	return args
+}
+
+// displayArgs devuelve los argumentos de la interfaz gráfica. Con window
+// se abre siempre una ventana, GTK salvo que la configuración pida SDL.
+func displayArgs(config *QemuConfig, window bool) []string {
+	display := config.Display
+	if window && display != DisplayGTK && display != DisplaySDL {
+		display = DefaultDisplay
+	}
+
+	switch display {
+	case DisplayGTK:
+		return []string{"-display", "gtk"}
+	case DisplaySDL:
+		return []string{"-display", "sdl"}
+	case DisplayVNC:
+		return []string{
+			"-vnc", fmt.Sprintf(":%d", config.VNCPort-5900),
+			"-display", "none"}
+	default:
+		return []string{"-display", "none"}
+	}
+}
+
+// qemuOptValue duplica las comas de un valor de una lista de opciones de
+// QEMU (-chardev, -drive...), que si no las toma como separador
+func qemuOptValue(s string) string {
+	return strings.ReplaceAll(s, ",", ",,")
+}
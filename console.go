@@ -0,0 +1,252 @@
+package goqemu
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const consoleBufferSize = 1 << 20 // Datos recibidos sin leer que conserva la consola
+
+// ErrExpectTimeout se devuelve cuando Expect no encuentra el patrón a tiempo
+var ErrExpectTimeout = errors.New("timeout esperando el patrón en la consola")
+
+// consolePromptRe reconoce el prompt de una shell, p. ej. "root@debian:~# "
+const consolePromptRe = `[#$] ?`
+
+// Console es el puerto serie de la VM. Permite manejar invitados sin red ni
+// SSH: Read y Write acceden directamente al puerto y Expect y Send
+// automatizan diálogos como el login.
+type Console struct {
+	conn net.Conn
+
+	mu     sync.Mutex
+	buf    []byte        // datos recibidos que todavía no se leyeron
+	err    error         // error de lectura del socket, p. ej. io.EOF
+	notify chan struct{} // se cierra al recibir datos o al fallar la lectura
+}
+
+// serialSocketPath devuelve la ruta del socket del puerto serie de la VM
+func (vm *QemuVM) serialSocketPath() string {
+	return filepath.Join(vm.workDir, "serial.sock")
+}
+
+// ConsoleLogPath devuelve la ruta de la transcripción de la consola: toda
+// la salida del puerto serie desde el arranque, haya o no una Console abierta
+func (vm *QemuVM) ConsoleLogPath() string {
+	return filepath.Join(vm.workDir, "console.log")
+}
+
+// serialArgs genera los argumentos del puerto serie en un socket unix
+func (vm *QemuVM) serialArgs() []string {
+	return []string{
+		"-chardev", fmt.Sprintf("socket,id=serial0,path=%s,server=on,wait=off,logfile=%s,logappend=off",
+			qemuOptValue(vm.serialSocketPath()), qemuOptValue(vm.ConsoleLogPath())),
+		"-serial", "chardev:serial0",
+	}
+}
+
+// Console se conecta al puerto serie de la VM. QEMU admite un único cliente
+// a la vez, por eso la consola se comparte y se cierra al detener la VM.
+func (vm *QemuVM) Console() (*Console, error) {
+	switch s := vm.Status(); s {
+	case StateStarting, StateRunning, StatePaused:
+	default:
+		return nil, &StateError{Op: "Console", State: s}
+	}
+
+	vm.consoleMu.Lock()
+	defer vm.consoleMu.Unlock()
+	if vm.console != nil && !vm.console.closed() {
+		return vm.console, nil
+	}
+
+	conn, err := net.DialTimeout("unix", vm.serialSocketPath(), 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("error conectando con la consola serie: %v", err)
+	}
+
+	vm.console = newConsole(conn)
+	return vm.console, nil
+}
+
+// closeConsole cierra la consola compartida de la VM
+func (vm *QemuVM) closeConsole() {
+	vm.consoleMu.Lock()
+	defer vm.consoleMu.Unlock()
+	if vm.console != nil {
+		vm.console.Close()
+		vm.console = nil
+	}
+}
+
+func newConsole(conn net.Conn) *Console {
+	c := &Console{conn: conn, notify: make(chan struct{})}
+	go c.readLoop()
+	return c
+}
+
+// readLoop acumula en buf todo lo que llega por el puerto serie
+func (c *Console) readLoop() {
+	data := make([]byte, 4096)
+	for {
+		n, err := c.conn.Read(data)
+
+		c.mu.Lock()
+		c.buf = append(c.buf, data[:n]...)
+		if extra := len(c.buf) - consoleBufferSize; extra > 0 {
+			c.buf = c.buf[extra:]
+		}
+		if err != nil {
+			c.err = err
+		}
+		close(c.notify)
+		c.notify = make(chan struct{})
+		c.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read lee los datos recibidos por el puerto serie que no consumió Expect
+func (c *Console) Read(p []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		if len(c.buf) > 0 {
+			n := copy(p, c.buf)
+			c.buf = c.buf[n:]
+			c.mu.Unlock()
+			return n, nil
+		}
+		if c.err != nil {
+			err := c.err
+			c.mu.Unlock()
+			return 0, err
+		}
+		notify := c.notify
+		c.mu.Unlock()
+		<-notify
+	}
+}
+
+// Write escribe p en el puerto serie
+func (c *Console) Write(p []byte) (int, error) {
+	return c.conn.Write(p)
+}
+
+// Send escribe s en el puerto serie
+func (c *Console) Send(s string) error {
+	_, err := c.conn.Write([]byte(s))
+	return err
+}
+
+// SendLine escribe s seguido de un retorno de carro, como la tecla Enter
+func (c *Console) SendLine(s string) error {
+	return c.Send(s + "\r")
+}
+
+// Expect espera a que la salida de la consola contenga la expresión regular
+// pattern y devuelve la salida hasta el final de la coincidencia, que se
+// consume. Si no aparece en timeout devuelve un error ErrExpectTimeout.
+func (c *Console) Expect(pattern string, timeout time.Duration) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("patrón inválido: %v", err)
+	}
+	return c.expect(re, time.Now().Add(timeout))
+}
+
+func (c *Console) expect(re *regexp.Regexp, deadline time.Time) (string, error) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	for {
+		c.mu.Lock()
+		if loc := re.FindIndex(c.buf); loc != nil {
+			out := string(c.buf[:loc[1]])
+			c.buf = c.buf[loc[1]:]
+			c.mu.Unlock()
+			return out, nil
+		}
+		if c.err != nil {
+			err := c.err
+			c.mu.Unlock()
+			return "", fmt.Errorf("consola cerrada esperando %q: %w", re, err)
+		}
+		notify := c.notify
+		tail := string(c.buf[max(0, len(c.buf)-200):])
+		c.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-timer.C:
+			return "", fmt.Errorf("%w %q, últimos datos: %q", ErrExpectTimeout, re, tail)
+		}
+	}
+}
+
+// Login inicia sesión en el getty del puerto serie con user y password,
+// que puede estar vacía si el invitado no la pide. Termina al aparecer el
+// prompt de la shell; si ya había una sesión abierta no hace nada.
+func (c *Console) Login(user, password string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	// Descartar la salida anterior: un prompt antiguo confundiría la espera
+	c.mu.Lock()
+	c.buf = nil
+	c.mu.Unlock()
+
+	// Enter para que el getty vuelva a mostrar su prompt
+	if err := c.SendLine(""); err != nil {
+		return err
+	}
+	out, err := c.expect(regexp.MustCompile(`(login: ?|`+consolePromptRe+`)$`), deadline)
+	if err != nil {
+		return fmt.Errorf("error esperando el login: %w", err)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(out), "login:") {
+		return nil
+	}
+
+	if err := c.SendLine(user); err != nil {
+		return err
+	}
+	out, err = c.expect(regexp.MustCompile(`([Pp]assword: ?|login: ?|`+consolePromptRe+`)$`), deadline)
+	if err != nil {
+		return fmt.Errorf("error esperando la contraseña: %w", err)
+	}
+
+	if strings.HasSuffix(strings.ToLower(strings.TrimSpace(out)), "password:") {
+		if err := c.SendLine(password); err != nil {
+			return err
+		}
+		out, err = c.expect(regexp.MustCompile(`(login: ?|`+consolePromptRe+`)$`), deadline)
+		if err != nil {
+			return fmt.Errorf("error esperando el prompt: %w", err)
+		}
+	}
+
+	if strings.HasSuffix(strings.TrimSpace(out), "login:") {
+		return fmt.Errorf("login incorrecto para el usuario %q", user)
+	}
+	return nil
+}
+
+// Close cierra la conexión con el puerto serie
+func (c *Console) Close() error {
+	return c.conn.Close()
+}
+
+// closed indica si la conexión con el puerto serie terminó
+func (c *Console) closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err != nil
+}
@@ -0,0 +1,154 @@
+package goqemu
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGetty simula el puerto serie de un invitado: pide usuario y
+// contraseña y después repite cada línea recibida tras el prompt
+func fakeGetty(t *testing.T, path, user, password string) {
+	t.Helper()
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Error creando el socket serie: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		readLine := func() (string, bool) {
+			line, err := r.ReadString('\r')
+			return strings.TrimSuffix(line, "\r"), err == nil
+		}
+
+		io.WriteString(conn, "Debian GNU/Linux 12 debian ttyS0\r\n\r\n")
+		for {
+			io.WriteString(conn, "debian login: ")
+			name, ok := readLine()
+			if !ok {
+				return
+			}
+			if name == "" {
+				continue
+			}
+			io.WriteString(conn, name+"\r\nPassword: ")
+			pass, ok := readLine()
+			if !ok {
+				return
+			}
+			if name == user && pass == password {
+				break
+			}
+			io.WriteString(conn, "\r\nLogin incorrect\r\n")
+		}
+
+		io.WriteString(conn, "\r\nLast login: Mon Jan  1 00:00:00 UTC 2024 on ttyS0\r\nroot@debian:~# ")
+		for {
+			line, ok := readLine()
+			if !ok {
+				return
+			}
+			io.WriteString(conn, line+"\r\n"+strings.ToUpper(line)+"\r\nroot@debian:~# ")
+		}
+	}()
+}
+
+func TestConsoleLogin(t *testing.T) {
+	vm := &QemuVM{config: &QemuConfig{}, state: StateRunning, workDir: t.TempDir()}
+	fakeGetty(t, vm.serialSocketPath(), "root", "secreto")
+	t.Cleanup(vm.closeConsole)
+
+	console, err := vm.Console()
+	if err != nil {
+		t.Fatalf("Error abriendo la consola: %v", err)
+	}
+	if again, _ := vm.Console(); again != console {
+		t.Error("La consola abierta debería compartirse")
+	}
+
+	if err := console.Login("root", "malo", 2*time.Second); err == nil {
+		t.Fatal("Se esperaba un login incorrecto")
+	}
+	if err := console.Login("root", "secreto", 2*time.Second); err != nil {
+		t.Fatalf("Error en el login: %v", err)
+	}
+
+	// Con la sesión abierta Login no hace nada
+	if err := console.Login("root", "secreto", 2*time.Second); err != nil {
+		t.Fatalf("Error en el segundo login: %v", err)
+	}
+
+	if err := console.SendLine("hola"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := console.Expect(`HOLA\r\n`, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Expect: %v", err)
+	}
+	if !strings.HasPrefix(out, "hola\r\n") {
+		t.Errorf("Salida inesperada: %q", out)
+	}
+
+	// Read devuelve lo que Expect no consumió
+	buf := make([]byte, 64)
+	n, err := console.Read(buf)
+	if err != nil || !strings.HasPrefix("root@debian:~# ", string(buf[:n])) {
+		t.Errorf("Read: %q, %v", buf[:n], err)
+	}
+}
+
+func TestConsoleExpectTimeout(t *testing.T) {
+	vm := &QemuVM{config: &QemuConfig{}, state: StateRunning, workDir: t.TempDir()}
+	fakeGetty(t, vm.serialSocketPath(), "root", "")
+	t.Cleanup(vm.closeConsole)
+
+	console, err := vm.Console()
+	if err != nil {
+		t.Fatalf("Error abriendo la consola: %v", err)
+	}
+
+	start := time.Now()
+	_, err = console.Expect(`nunca`, 300*time.Millisecond)
+	if !errors.Is(err, ErrExpectTimeout) || !strings.Contains(err.Error(), "login:") {
+		t.Fatalf("Se esperaba ErrExpectTimeout con los últimos datos, obtenido: %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Expect no respetó el timeout: %v", time.Since(start))
+	}
+
+	vm.closeConsole()
+	if _, err := (&QemuVM{state: StateStopped}).Console(); err == nil {
+		t.Error("Una VM detenida no debería tener consola")
+	}
+}
+
+func TestChardevPathsEscapeCommas(t *testing.T) {
+	vm := &QemuVM{config: &QemuConfig{GuestAgent: true}, workDir: "/home/a,b/qemu/vms/vm-1"}
+
+	args := append(vm.serialArgs(), vm.qmpArgs()...)
+	args = append(args, vm.guestAgentArgs()...)
+	cmdline := strings.Join(args, " ")
+	for _, want := range []string{
+		"path=/home/a,,b/qemu/vms/vm-1/serial.sock,server=on",
+		"logfile=/home/a,,b/qemu/vms/vm-1/console.log,",
+		"unix:/home/a,,b/qemu/vms/vm-1/qmp.sock,server=on",
+		"path=/home/a,,b/qemu/vms/vm-1/qga.sock,server=on",
+	} {
+		if !strings.Contains(cmdline, want) {
+			t.Errorf("Falta %q en: %s", want, cmdline)
+		}
+	}
+}
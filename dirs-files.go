@@ -1,8 +1,11 @@
 package goqemu
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // createQemuDirs crea los directorios base para QEMU si no existen
@@ -27,3 +30,25 @@ func createQemuDirs() error {
 
 	return nil
 }
+
+// generateVMID genera un identificador único para una VM
+func generateVMID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return "vm-" + time.Now().Format("20060102150405") + "-" + hex.EncodeToString(b)
+}
+
+// getVMDir devuelve el directorio de trabajo de una VM (sockets, logs, pid)
+func getVMDir(id string) string {
+	return filepath.Join(os.Getenv("HOME"), "qemu", "vms", id)
+}
+
+// createVMDir crea el directorio de trabajo de una VM si no existe
+func createVMDir(id string) (string, error) {
+	dir := getVMDir(id)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
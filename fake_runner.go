@@ -0,0 +1,287 @@
+package goqemu
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// FakeRunner es un Runner que no ejecuta nada: registra las llamadas y
+// devuelve salidas preconfiguradas. Permite probar las líneas de comando
+// que genera goqemu sin tener QEMU instalado.
+type FakeRunner struct {
+	mu        sync.Mutex
+	calls     []RunnerCall
+	outputs   map[string]fakeOutput
+	missing   map[string]bool
+	processes []*FakeProcess
+	nextPid   int
+
+	// OnStart se llama con cada proceso lanzado, p. ej. para simular
+	// que QEMU falla al arrancar con p.Exit
+	OnStart func(p *FakeProcess)
+}
+
+// RunnerCall es una llamada registrada por FakeRunner
+type RunnerCall struct {
+	Method string // "LookPath", "Start" u "Output"
+	Name   string
+	Args   []string
+}
+
+// CommandLine devuelve la llamada como una línea de comando
+func (c RunnerCall) CommandLine() string {
+	return strings.TrimSpace(c.Name + " " + strings.Join(c.Args, " "))
+}
+
+// Salidas de ayuda de un qemu-system-x86_64 8.2 típico
+const (
+	fakeMachineHelp = `Supported machines are:
+pc                   Standard PC (i440FX + PIIX, 1996) (alias of pc-i440fx-8.2)
+pc-i440fx-8.2        Standard PC (i440FX + PIIX, 1996) (default)
+q35                  Standard PC (Q35 + ICH9, 2009) (alias of pc-q35-8.2)
+pc-q35-8.2           Standard PC (Q35 + ICH9, 2009)
+microvm              microvm (i386)
+none                 empty machine
+`
+	fakeAccelHelp = `Accelerators supported in QEMU binary:
+tcg
+kvm
+`
+	fakeDisplayHelp = `Available display backend types:
+none
+gtk
+sdl
+egl-headless
+curses
+`
+	fakeDeviceHelp = `Storage devices:
+name "virtio-blk-pci", bus PCI, alias "virtio-blk"
+
+Network devices:
+name "e1000", bus PCI, alias "e1000-82540em", desc "Intel Gigabit Ethernet"
+name "rtl8139", bus PCI
+name "virtio-net-pci", bus PCI, alias "virtio-net"
+
+Misc devices:
+name "virtio-serial-pci", bus PCI, alias "virtio-serial"
+name "virtserialport", bus virtio-serial-bus
+`
+)
+
+type fakeOutput struct {
+	out []byte
+	err error
+}
+
+// NewFakeRunner crea un FakeRunner que simula una instalación de QEMU 8.2.0
+func NewFakeRunner() *FakeRunner {
+	f := &FakeRunner{
+		outputs: make(map[string]fakeOutput),
+		missing: make(map[string]bool),
+		nextPid: 10000,
+	}
+	f.SetOutput("qemu-system-x86_64 --version", "QEMU emulator version 8.2.0\n", nil)
+	f.SetOutput("qemu-system-x86_64 -machine help", fakeMachineHelp, nil)
+	f.SetOutput("qemu-system-x86_64 -accel help", fakeAccelHelp, nil)
+	f.SetOutput("qemu-system-x86_64 -display help", fakeDisplayHelp, nil)
+	f.SetOutput("qemu-system-x86_64 -device help", fakeDeviceHelp, nil)
+	return f
+}
+
+// SetOutput configura la salida de Output para una línea de comando exacta
+// ("qemu-img snapshot -l disk.qcow2") o para cualquier llamada a un binario ("qemu-img")
+func (f *FakeRunner) SetOutput(commandLine string, output string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.outputs[commandLine] = fakeOutput{out: []byte(output), err: err}
+}
+
+// SetMissing simula que un binario no está instalado
+func (f *FakeRunner) SetMissing(file string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.missing[file] = true
+}
+
+// Calls devuelve todas las llamadas registradas
+func (f *FakeRunner) Calls() []RunnerCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	calls := make([]RunnerCall, len(f.calls))
+	copy(calls, f.calls)
+	return calls
+}
+
+// CallsTo devuelve las llamadas registradas a un binario
+func (f *FakeRunner) CallsTo(name string) []RunnerCall {
+	var calls []RunnerCall
+	for _, c := range f.Calls() {
+		if c.Name == name {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Processes devuelve los procesos lanzados con Start
+func (f *FakeRunner) Processes() []*FakeProcess {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	procs := make([]*FakeProcess, len(f.processes))
+	copy(procs, f.processes)
+	return procs
+}
+
+func (f *FakeRunner) record(method, name string, args []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, RunnerCall{Method: method, Name: name, Args: append([]string(nil), args...)})
+}
+
+// LookPath devuelve /usr/bin/<file> salvo que se haya marcado como ausente
+func (f *FakeRunner) LookPath(file string) (string, error) {
+	f.record("LookPath", file, nil)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.missing[file] {
+		return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+	}
+	return "/usr/bin/" + file, nil
+}
+
+// Start registra la llamada y devuelve un FakeProcess que sigue en
+// ejecución hasta que se llame a Exit, Terminate o Kill
+func (f *FakeRunner) Start(name string, args []string, stdout, stderr io.Writer) (Process, error) {
+	f.record("Start", name, args)
+
+	f.mu.Lock()
+	if f.missing[name] {
+		f.mu.Unlock()
+		return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	f.nextPid++
+	p := &FakeProcess{
+		Name:   name,
+		Args:   append([]string(nil), args...),
+		Stdout: stdout,
+		Stderr: stderr,
+		pid:    f.nextPid,
+		done:   make(chan struct{}),
+	}
+	f.processes = append(f.processes, p)
+	onStart := f.OnStart
+	f.mu.Unlock()
+
+	if onStart != nil {
+		onStart(p)
+	}
+	return p, nil
+}
+
+// Output devuelve la salida configurada para la línea de comando exacta
+// o, en su defecto, para el binario. Sin configuración devuelve una salida vacía.
+func (f *FakeRunner) Output(name string, args ...string) ([]byte, error) {
+	call := RunnerCall{Method: "Output", Name: name, Args: args}
+	f.record("Output", name, args)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.missing[name] {
+		return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	if o, ok := f.outputs[call.CommandLine()]; ok {
+		return o.out, o.err
+	}
+	if o, ok := f.outputs[name]; ok {
+		return o.out, o.err
+	}
+	return nil, nil
+}
+
+// FakeProcess es un proceso simulado lanzado por FakeRunner
+type FakeProcess struct {
+	Name   string
+	Args   []string
+	Stdout io.Writer
+	Stderr io.Writer
+
+	pid  int
+	once sync.Once
+	exit ProcessExit
+	done chan struct{}
+
+	mu         sync.Mutex
+	terminated bool
+	killed     bool
+}
+
+// Exit termina el proceso simulado con el código y motivo indicados
+func (p *FakeProcess) Exit(code int, reason string) {
+	p.once.Do(func() {
+		if reason == "" {
+			reason = fmt.Sprintf("exit status %d", code)
+		}
+		p.exit = ProcessExit{Code: code, Reason: reason}
+		close(p.done)
+	})
+}
+
+// Exited indica si el proceso simulado terminó
+func (p *FakeProcess) Exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Terminated indica si se llamó a Terminate
+func (p *FakeProcess) Terminated() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.terminated
+}
+
+// Killed indica si se llamó a Kill
+func (p *FakeProcess) Killed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.killed
+}
+
+func (p *FakeProcess) Pid() int {
+	return p.pid
+}
+
+func (p *FakeProcess) Wait() (ProcessExit, error) {
+	<-p.done
+	return p.exit, nil
+}
+
+func (p *FakeProcess) Terminate() error {
+	if p.Exited() {
+		return errors.New("el proceso ya terminó")
+	}
+	p.mu.Lock()
+	p.terminated = true
+	p.mu.Unlock()
+	p.Exit(-1, "signal: terminated")
+	return nil
+}
+
+func (p *FakeProcess) Kill() error {
+	if p.Exited() {
+		return nil
+	}
+	p.mu.Lock()
+	p.killed = true
+	p.mu.Unlock()
+	p.Exit(-1, "signal: killed")
+	return nil
+}
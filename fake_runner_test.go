@@ -0,0 +1,41 @@
+package goqemu
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckQemuInstalledFake(t *testing.T) {
+	runner := NewFakeRunner()
+	if _, err := checkQemuInstalled(runner); err != nil {
+		t.Fatalf("Error verificando QEMU simulado: %v", err)
+	}
+
+	runner.SetMissing("qemu-system-x86_64")
+	if _, err := checkQemuInstalled(runner); err == nil {
+		t.Error("Se esperaba error con QEMU ausente")
+	}
+}
+
+func TestSnapshotCommandLine(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	runner := NewFakeRunner()
+	vm := &QemuVM{config: &QemuConfig{}, state: StateRunning, runner: runner}
+
+	snapshot, err := vm.CreateSnapshot("[test]")
+	if err != nil {
+		t.Fatalf("Error creando snapshot: %v", err)
+	}
+
+	calls := runner.CallsTo("qemu-img")
+	if len(calls) != 1 {
+		t.Fatalf("Se esperaba una llamada a qemu-img, obtenidas: %v", calls)
+	}
+	snapPath := filepath.Join(home, "qemu", "snapshots", snapshot.ID+".qcow2")
+	want := "qemu-img snapshot -c " + snapshot.ID + " " + snapPath
+	if got := calls[0].CommandLine(); got != want {
+		t.Errorf("Línea de comando esperada %q, obtenida %q", want, got)
+	}
+}
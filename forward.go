@@ -0,0 +1,292 @@
+package goqemu
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Forward es un túnel SSH abierto con ForwardLocal o ForwardRemote. Se
+// cierra con Close o automáticamente al detener la VM.
+type Forward struct {
+	Remote     bool   // true si escucha en el invitado (ForwardRemote)
+	TargetAddr string // dirección a la que se conectan las conexiones aceptadas
+
+	vm         *QemuVM
+	ln         net.Listener
+	listenAddr string // protegida por mu: cambia si ForwardRemote vuelve a escuchar
+
+	active atomic.Int64
+	total  atomic.Int64
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Addr devuelve la dirección en la que escucha el túnel, con el puerto
+// real. En ForwardRemote cambia si el invitado vuelve a escuchar tras una
+// reconexión.
+func (f *Forward) Addr() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.listenAddr
+}
+
+// Active devuelve el número de conexiones abiertas por el túnel
+func (f *Forward) Active() int64 {
+	return f.active.Load()
+}
+
+// Total devuelve el número de conexiones aceptadas desde que se abrió
+func (f *Forward) Total() int64 {
+	return f.total.Load()
+}
+
+// Close deja de aceptar conexiones y cierra las abiertas
+func (f *Forward) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	ln := f.ln
+	for c := range f.conns {
+		c.Close()
+	}
+	f.mu.Unlock()
+
+	err := ln.Close()
+	f.wg.Wait()
+	f.vm.removeForward(f)
+	return err
+}
+
+// ForwardLocal escucha en hostAddr del host y reenvía cada conexión a
+// guestAddr a través de SSH, p. ej. ForwardLocal("127.0.0.1:0", "localhost:5432").
+// Con puerto 0 se elige uno libre, disponible en Addr.
+func (vm *QemuVM) ForwardLocal(hostAddr, guestAddr string) (*Forward, error) {
+	if err := vm.checkSSHAvailable("ForwardLocal"); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("tcp", hostAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	f := vm.newForward(false, ln, guestAddr)
+	f.wg.Add(1)
+	go f.serve(func() (net.Conn, error) {
+		return vm.dialGuest(guestAddr)
+	})
+	return f, nil
+}
+
+// ForwardRemote escucha en guestAddr del invitado y reenvía cada conexión
+// a hostAddr del host, p. ej. ForwardRemote("127.0.0.1:8080", "localhost:3000").
+// Si la conexión SSH se restablece el túnel vuelve a escuchar en el invitado.
+func (vm *QemuVM) ForwardRemote(guestAddr, hostAddr string) (*Forward, error) {
+	if err := vm.checkSSHAvailable("ForwardRemote"); err != nil {
+		return nil, err
+	}
+
+	client := vm.currentSSH()
+	if client == nil {
+		return nil, &ConnError{Op: "forward", Err: errors.New("no hay conexión SSH con la VM")}
+	}
+	ln, err := client.Listen("tcp", guestAddr)
+	if err != nil {
+		return nil, &ConnError{Op: "forward", Err: err}
+	}
+
+	f := vm.newForward(true, ln, hostAddr)
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		for {
+			f.wg.Add(1)
+			f.serve(func() (net.Conn, error) {
+				return net.DialTimeout("tcp", hostAddr, 10*time.Second)
+			})
+			if !f.relisten(guestAddr) {
+				return
+			}
+		}
+	}()
+	return f, nil
+}
+
+func (vm *QemuVM) newForward(remote bool, ln net.Listener, target string) *Forward {
+	f := &Forward{
+		Remote:     remote,
+		listenAddr: ln.Addr().String(),
+		TargetAddr: target,
+		vm:         vm,
+		ln:         ln,
+		conns:      make(map[net.Conn]struct{}),
+	}
+
+	vm.forwardsMu.Lock()
+	if vm.forwards == nil {
+		vm.forwards = make(map[*Forward]struct{})
+	}
+	vm.forwards[f] = struct{}{}
+	vm.forwardsMu.Unlock()
+	return f
+}
+
+// dialGuest abre una conexión a addr desde el invitado, reconectando SSH
+// una vez si la conexión está rota
+func (vm *QemuVM) dialGuest(addr string) (net.Conn, error) {
+	client := vm.currentSSH()
+	if client != nil {
+		if conn, err := client.Dial("tcp", addr); err == nil {
+			return conn, nil
+		}
+	}
+
+	if err := vm.reconnectSSH(vm.runContext(), client); err != nil {
+		return nil, err
+	}
+	if client = vm.currentSSH(); client == nil {
+		return nil, &ConnError{Op: "forward", Err: errors.New("no hay conexión SSH con la VM")}
+	}
+	return client.Dial("tcp", addr)
+}
+
+// serve acepta conexiones hasta que se cierra el listener y copia los
+// datos en ambos sentidos con la conexión que devuelve dial
+func (f *Forward) serve(dial func() (net.Conn, error)) {
+	defer f.wg.Done()
+
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		if !f.track(conn) {
+			conn.Close()
+			return
+		}
+		f.total.Add(1)
+		f.active.Add(1)
+
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			defer f.active.Add(-1)
+			defer f.untrack(conn)
+			defer conn.Close()
+
+			target, err := dial()
+			if err != nil {
+				return
+			}
+			if !f.track(target) {
+				target.Close()
+				return
+			}
+			defer f.untrack(target)
+			defer target.Close()
+
+			pipe(conn, target)
+		}()
+	}
+}
+
+// relisten vuelve a escuchar en el invitado tras perder la conexión SSH.
+// Devuelve false si el túnel se cerró o no se pudo restablecer.
+func (f *Forward) relisten(guestAddr string) bool {
+	deadline := time.Now().Add(f.vm.reconnectTimeout())
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		closed := f.closed
+		f.mu.Unlock()
+		if closed {
+			return false
+		}
+
+		if client := f.vm.currentSSH(); client != nil {
+			if ln, err := client.Listen("tcp", guestAddr); err == nil {
+				f.mu.Lock()
+				defer f.mu.Unlock()
+				if f.closed {
+					ln.Close()
+					return false
+				}
+				// Con puerto 0 el invitado puede asignar otro puerto
+				f.ln = ln
+				f.listenAddr = ln.Addr().String()
+				return true
+			}
+		}
+		time.Sleep(reconnectBackoffMin)
+	}
+	return false
+}
+
+func (f *Forward) track(c net.Conn) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return false
+	}
+	f.conns[c] = struct{}{}
+	return true
+}
+
+func (f *Forward) untrack(c net.Conn) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.conns, c)
+}
+
+// pipe copia en ambos sentidos hasta que los dos extremos terminan. Al
+// acabar un sentido se cierra solo la escritura del destino, así un cliente
+// que cierra su envío recibe igualmente la respuesta.
+func pipe(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	copyHalf := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+		done <- struct{}{}
+	}
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+	<-done
+	<-done
+}
+
+// Forwards devuelve los túneles abiertos de la VM
+func (vm *QemuVM) Forwards() []*Forward {
+	vm.forwardsMu.Lock()
+	defer vm.forwardsMu.Unlock()
+	list := make([]*Forward, 0, len(vm.forwards))
+	for f := range vm.forwards {
+		list = append(list, f)
+	}
+	return list
+}
+
+func (vm *QemuVM) removeForward(f *Forward) {
+	vm.forwardsMu.Lock()
+	defer vm.forwardsMu.Unlock()
+	delete(vm.forwards, f)
+}
+
+// closeForwards cierra todos los túneles de la VM
+func (vm *QemuVM) closeForwards() {
+	for _, f := range vm.Forwards() {
+		f.Close()
+	}
+}
@@ -0,0 +1,160 @@
+package goqemu
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// echoServer escucha en el host y devuelve cada línea recibida
+func echoServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error escuchando: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					fmt.Fprintf(conn, "eco: %s\n", scanner.Text())
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// roundTrip envía una línea a addr y devuelve la respuesta
+func roundTrip(t *testing.T, addr, msg string) string {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Error conectando a %s: %v", addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	fmt.Fprintln(conn, msg)
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Error leyendo respuesta: %v", err)
+	}
+	return reply
+}
+
+func TestForwardLocal(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+	guestService := echoServer(t)
+
+	f, err := vm.ForwardLocal("127.0.0.1:0", guestService)
+	if err != nil {
+		t.Fatalf("Error abriendo túnel: %v", err)
+	}
+
+	if got := roundTrip(t, f.Addr(), "hola"); got != "eco: hola\n" {
+		t.Errorf("Respuesta inesperada: %q", got)
+	}
+	roundTrip(t, f.Addr(), "otra")
+	if f.Total() != 2 {
+		t.Errorf("Conexiones totales %d, se esperaban 2", f.Total())
+	}
+
+	// Al detener la VM se cierran todos los túneles
+	vm.closeForwards()
+	if len(vm.Forwards()) != 0 {
+		t.Error("Quedaron túneles abiertos")
+	}
+	if _, err := net.DialTimeout("tcp", f.Addr(), time.Second); err == nil {
+		t.Error("El túnel sigue aceptando conexiones tras cerrarse")
+	}
+}
+
+func TestForwardRemote(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+	hostService := echoServer(t)
+
+	f, err := vm.ForwardRemote("127.0.0.1:0", hostService)
+	if err != nil {
+		t.Fatalf("Error abriendo túnel remoto: %v", err)
+	}
+	defer f.Close()
+
+	// El "invitado" se conecta a su puerto y llega al servicio del host
+	if got := roundTrip(t, f.Addr(), "desde el invitado"); got != "eco: desde el invitado\n" {
+		t.Errorf("Respuesta inesperada: %q", got)
+	}
+	if f.Total() != 1 {
+		t.Errorf("Conexiones totales %d, se esperaba 1", f.Total())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for f.Active() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if f.Active() != 0 {
+		t.Errorf("Conexiones activas %d tras cerrar, se esperaba 0", f.Active())
+	}
+}
+
+func TestPipeHalfClose(t *testing.T) {
+	// Servicio que responde después de leer toda la petición
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	go func() {
+		conn, err := backend.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, _ := io.ReadAll(conn)
+		fmt.Fprintf(conn, "recibido: %s", req)
+	}()
+
+	front, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer front.Close()
+	go func() {
+		conn, err := front.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		target, err := net.Dial("tcp", backend.Addr().String())
+		if err != nil {
+			return
+		}
+		defer target.Close()
+		pipe(conn, target)
+	}()
+
+	conn, err := net.Dial("tcp", front.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	fmt.Fprint(conn, "petición")
+	conn.(*net.TCPConn).CloseWrite()
+	reply, err := io.ReadAll(conn)
+	if err != nil || string(reply) != "recibido: petición" {
+		t.Errorf("Respuesta inesperada tras cerrar el envío: %q, %v", reply, err)
+	}
+}
@@ -2,6 +2,9 @@ module github.com/cdvelop/goqemu
 
 go 1.22.0
 
-require golang.org/x/crypto v0.32.0
+require (
+	golang.org/x/crypto v0.32.0
+	golang.org/x/term v0.28.0
+)
 
 require golang.org/x/sys v0.29.0 // indirect
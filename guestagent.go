@@ -0,0 +1,452 @@
+package goqemu
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	guestAgentTimeout = 10 * time.Second       // Tiempo máximo por comando si ctx no tiene deadline
+	guestExecPoll     = 100 * time.Millisecond // Intervalo de consulta de guest-exec-status
+	guestFileChunk    = 48 * 1024              // Bytes por guest-file-read/write
+)
+
+// GuestAgentError es un error devuelto por qemu-guest-agent
+type GuestAgentError struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+func (e *GuestAgentError) Error() string {
+	return fmt.Sprintf("guest agent %s: %s", e.Class, e.Desc)
+}
+
+// GuestExecResult es el resultado de un proceso ejecutado con Exec
+type GuestExecResult struct {
+	ExitCode int
+	Signal   int // señal que terminó el proceso, 0 si terminó normalmente
+	Stdout   string
+	Stderr   string
+}
+
+// GuestInterface es una interfaz de red del invitado
+type GuestInterface struct {
+	Name            string           `json:"name"`
+	HardwareAddress string           `json:"hardware-address"`
+	IPAddresses     []GuestIPAddress `json:"ip-addresses"`
+}
+
+// GuestIPAddress es una dirección IP de una interfaz del invitado
+type GuestIPAddress struct {
+	Type    string `json:"ip-address-type"` // ipv4 o ipv6
+	Address string `json:"ip-address"`
+	Prefix  int    `json:"prefix"`
+}
+
+// GuestAgent es un cliente de qemu-guest-agent conectado por un canal
+// virtio-serial. Es una vía de control independiente de la red y de SSH.
+// El protocolo no identifica las respuestas, así que los comandos se
+// ejecutan de uno en uno.
+type GuestAgent struct {
+	socketPath string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	dec    *json.Decoder
+	syncID int64
+}
+
+type guestAgentRequest struct {
+	Execute   string `json:"execute"`
+	Arguments any    `json:"arguments,omitempty"`
+}
+
+type guestAgentResponse struct {
+	Return json.RawMessage  `json:"return"`
+	Error  *GuestAgentError `json:"error"`
+}
+
+// guestAgentSocketPath devuelve la ruta del socket del canal del agente
+func (vm *QemuVM) guestAgentSocketPath() string {
+	return filepath.Join(vm.workDir, "qga.sock")
+}
+
+// guestAgentArgs genera los argumentos del canal virtio-serial del agente,
+// vacíos si GuestAgent no está habilitado
+func (vm *QemuVM) guestAgentArgs() []string {
+	if !vm.config.GuestAgent {
+		return nil
+	}
+	return []string{
+		"-chardev", fmt.Sprintf("socket,id=qga0,path=%s,server=on,wait=off", qemuOptValue(vm.guestAgentSocketPath())),
+		"-device", "virtio-serial",
+		"-device", "virtserialport,chardev=qga0,name=org.qemu.guest_agent.0",
+	}
+}
+
+// GuestAgent devuelve el cliente de qemu-guest-agent de la VM. Requiere
+// GuestAgent en la configuración y el agente instalado en el invitado.
+func (vm *QemuVM) GuestAgent() (*GuestAgent, error) {
+	if !vm.config.GuestAgent && !vm.attached {
+		return nil, errors.New("el canal del guest agent no está habilitado, use WithGuestAgent")
+	}
+	switch s := vm.Status(); s {
+	case StateStarting, StateRunning:
+	default:
+		return nil, &StateError{Op: "GuestAgent", State: s}
+	}
+
+	vm.agentMu.Lock()
+	defer vm.agentMu.Unlock()
+	if vm.agent == nil {
+		vm.agent = &GuestAgent{socketPath: vm.guestAgentSocketPath()}
+	}
+	return vm.agent, nil
+}
+
+// closeGuestAgent cierra la conexión con el agente
+func (vm *QemuVM) closeGuestAgent() {
+	vm.agentMu.Lock()
+	defer vm.agentMu.Unlock()
+	if vm.agent != nil {
+		vm.agent.Close()
+		vm.agent = nil
+	}
+}
+
+// GuestIPs devuelve las direcciones IP reales del invitado, sin las de
+// loopback, según qemu-guest-agent
+func (vm *QemuVM) GuestIPs(ctx context.Context) ([]net.IP, error) {
+	agent, err := vm.GuestAgent()
+	if err != nil {
+		return nil, err
+	}
+	ifaces, err := agent.NetworkInterfaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, iface := range ifaces {
+		for _, addr := range iface.IPAddresses {
+			ip := net.ParseIP(addr.Address)
+			if ip != nil && !ip.IsLoopback() {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips, nil
+}
+
+// Execute ejecuta un comando del agente. Si result no es nil se decodifica
+// en él el campo "return" de la respuesta.
+func (a *GuestAgent) Execute(ctx context.Context, command string, args any, result any) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	resp, err := a.roundTrip(ctx, command, args, true)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil && len(resp.Return) > 0 {
+		if err := json.Unmarshal(resp.Return, result); err != nil {
+			return fmt.Errorf("error decodificando respuesta del agente a %s: %v", command, err)
+		}
+	}
+	return nil
+}
+
+// roundTrip envía un comando y, si wait, lee su respuesta. Ante cualquier
+// error la conexión se descarta: la siguiente vuelve a sincronizarse.
+func (a *GuestAgent) roundTrip(ctx context.Context, command string, args any, wait bool) (*guestAgentResponse, error) {
+	if err := a.connect(ctx); err != nil {
+		return nil, err
+	}
+
+	stop := a.setDeadline(ctx)
+	defer stop()
+
+	if err := a.send(command, args); err != nil {
+		a.drop()
+		return nil, a.ctxErr(ctx, fmt.Errorf("error enviando %s al agente: %v", command, err))
+	}
+	if !wait {
+		return nil, nil
+	}
+
+	var resp guestAgentResponse
+	if err := a.dec.Decode(&resp); err != nil {
+		a.drop()
+		return nil, a.ctxErr(ctx, fmt.Errorf("error leyendo la respuesta del agente a %s: %v", command, err))
+	}
+	return &resp, nil
+}
+
+// connect abre el socket y descarta cualquier respuesta antigua con
+// guest-sync-delimited: el agente antepone 0xFF a la respuesta
+func (a *GuestAgent) connect(ctx context.Context) error {
+	if a.conn != nil {
+		return nil
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", a.socketPath)
+	if err != nil {
+		return fmt.Errorf("error conectando con el guest agent: %v", err)
+	}
+	a.conn = conn
+	r := bufio.NewReader(conn)
+
+	stop := a.setDeadline(ctx)
+	defer stop()
+
+	a.syncID = time.Now().UnixNano() & (1<<53 - 1)
+	if err := a.send("guest-sync-delimited", map[string]any{"id": a.syncID}); err != nil {
+		a.drop()
+		return a.ctxErr(ctx, fmt.Errorf("error sincronizando con el guest agent: %v", err))
+	}
+
+	for {
+		if _, err := r.ReadBytes(0xFF); err != nil {
+			a.drop()
+			return a.ctxErr(ctx, fmt.Errorf("el guest agent no responde: %v", err))
+		}
+
+		dec := json.NewDecoder(r)
+		var resp struct {
+			Return int64 `json:"return"`
+		}
+		if err := dec.Decode(&resp); err == nil && resp.Return == a.syncID {
+			a.dec = dec
+			return nil
+		}
+		// Respuesta de una sincronización anterior: seguir buscando
+		r = bufio.NewReader(io.MultiReader(dec.Buffered(), r))
+	}
+}
+
+func (a *GuestAgent) send(command string, args any) error {
+	data, err := json.Marshal(guestAgentRequest{Execute: command, Arguments: args})
+	if err != nil {
+		return err
+	}
+	_, err = a.conn.Write(data)
+	return err
+}
+
+// setDeadline aplica a la conexión el deadline de ctx, o guestAgentTimeout,
+// y la interrumpe si ctx se cancela antes
+func (a *GuestAgent) setDeadline(ctx context.Context) (stop func()) {
+	conn := a.conn
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(guestAgentTimeout)
+	}
+	conn.SetDeadline(deadline)
+	cancel := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	return func() {
+		cancel()
+		conn.SetDeadline(time.Time{})
+	}
+}
+
+// ctxErr devuelve el error de ctx si la operación falló por cancelación
+func (a *GuestAgent) ctxErr(ctx context.Context, err error) error {
+	// El deadline del socket puede vencer un instante antes que ctx
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+		<-ctx.Done()
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("%v: %w", err, ctx.Err())
+	}
+	return err
+}
+
+func (a *GuestAgent) drop() {
+	if a.conn != nil {
+		a.conn.Close()
+		a.conn = nil
+		a.dec = nil
+	}
+}
+
+// Close cierra la conexión con el agente
+func (a *GuestAgent) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.drop()
+	return nil
+}
+
+// Ping comprueba que el agente del invitado responde
+func (a *GuestAgent) Ping(ctx context.Context) error {
+	return a.Execute(ctx, "guest-ping", nil, nil)
+}
+
+// Exec ejecuta path con args en el invitado, le pasa stdin y espera a que
+// termine consultando guest-exec-status. Si termina con un código distinto
+// de cero devuelve también un *ExitError.
+func (a *GuestAgent) Exec(ctx context.Context, path string, args []string, stdin []byte) (*GuestExecResult, error) {
+	req := map[string]any{
+		"path":           path,
+		"arg":            args,
+		"capture-output": true,
+	}
+	if len(stdin) > 0 {
+		req["input-data"] = base64.StdEncoding.EncodeToString(stdin)
+	}
+
+	var started struct {
+		Pid int `json:"pid"`
+	}
+	if err := a.Execute(ctx, "guest-exec", req, &started); err != nil {
+		return nil, err
+	}
+
+	for {
+		var status struct {
+			Exited   bool   `json:"exited"`
+			ExitCode int    `json:"exitcode"`
+			Signal   int    `json:"signal"`
+			OutData  string `json:"out-data"`
+			ErrData  string `json:"err-data"`
+		}
+		if err := a.Execute(ctx, "guest-exec-status", map[string]any{"pid": started.Pid}, &status); err != nil {
+			return nil, err
+		}
+
+		if status.Exited {
+			stdout, _ := base64.StdEncoding.DecodeString(status.OutData)
+			stderr, _ := base64.StdEncoding.DecodeString(status.ErrData)
+			res := &GuestExecResult{
+				ExitCode: status.ExitCode,
+				Signal:   status.Signal,
+				Stdout:   string(stdout),
+				Stderr:   string(stderr),
+			}
+			if res.ExitCode != 0 || res.Signal != 0 {
+				exitErr := &ExitError{Command: path, ExitStatus: res.ExitCode, Stderr: res.Stderr}
+				if res.Signal != 0 {
+					exitErr.Signal = fmt.Sprint(res.Signal)
+				}
+				return res, exitErr
+			}
+			return res, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("esperando el proceso %d del invitado: %w", started.Pid, ctx.Err())
+		case <-time.After(guestExecPoll):
+		}
+	}
+}
+
+// ReadFile lee un archivo del invitado con guest-file-open/read
+func (a *GuestAgent) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	handle, err := a.openFile(ctx, path, "r")
+	if err != nil {
+		return nil, err
+	}
+	defer a.closeFile(handle)
+
+	var data []byte
+	for {
+		var chunk struct {
+			Count  int    `json:"count"`
+			BufB64 string `json:"buf-b64"`
+			EOF    bool   `json:"eof"`
+		}
+		args := map[string]any{"handle": handle, "count": guestFileChunk}
+		if err := a.Execute(ctx, "guest-file-read", args, &chunk); err != nil {
+			return nil, err
+		}
+
+		b, err := base64.StdEncoding.DecodeString(chunk.BufB64)
+		if err != nil {
+			return nil, fmt.Errorf("error decodificando %s: %v", path, err)
+		}
+		data = append(data, b...)
+		if chunk.EOF || chunk.Count == 0 {
+			return data, nil
+		}
+	}
+}
+
+// WriteFile crea o reemplaza un archivo del invitado con guest-file-open/write
+func (a *GuestAgent) WriteFile(ctx context.Context, path string, data []byte) error {
+	handle, err := a.openFile(ctx, path, "w")
+	if err != nil {
+		return err
+	}
+
+	for len(data) > 0 {
+		n := min(len(data), guestFileChunk)
+		args := map[string]any{"handle": handle, "buf-b64": base64.StdEncoding.EncodeToString(data[:n])}
+		var written struct {
+			Count int `json:"count"`
+		}
+		if err := a.Execute(ctx, "guest-file-write", args, &written); err != nil {
+			a.closeFile(handle)
+			return err
+		}
+		if written.Count <= 0 {
+			a.closeFile(handle)
+			return fmt.Errorf("error escribiendo %s: %w", path, io.ErrShortWrite)
+		}
+		data = data[written.Count:]
+	}
+	return a.closeFile(handle)
+}
+
+func (a *GuestAgent) openFile(ctx context.Context, path, mode string) (int, error) {
+	var handle int
+	err := a.Execute(ctx, "guest-file-open", map[string]any{"path": path, "mode": mode}, &handle)
+	if err != nil {
+		return 0, fmt.Errorf("error abriendo %s en el invitado: %w", path, err)
+	}
+	return handle, nil
+}
+
+// closeFile cierra el handle aunque ctx se haya cancelado
+func (a *GuestAgent) closeFile(handle int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), guestAgentTimeout)
+	defer cancel()
+	return a.Execute(ctx, "guest-file-close", map[string]any{"handle": handle}, nil)
+}
+
+// NetworkInterfaces devuelve las interfaces de red del invitado con sus
+// direcciones IP
+func (a *GuestAgent) NetworkInterfaces(ctx context.Context) ([]GuestInterface, error) {
+	var ifaces []GuestInterface
+	if err := a.Execute(ctx, "guest-network-get-interfaces", nil, &ifaces); err != nil {
+		return nil, err
+	}
+	return ifaces, nil
+}
+
+// Shutdown pide al invitado que se apague: mode es "powerdown" (por
+// defecto), "halt" o "reboot". El agente no responde si la orden se acepta.
+func (a *GuestAgent) Shutdown(ctx context.Context, mode string) error {
+	if mode == "" {
+		mode = "powerdown"
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err := a.roundTrip(ctx, "guest-shutdown", map[string]any{"mode": mode}, false)
+	a.drop()
+	return err
+}
@@ -0,0 +1,249 @@
+package goqemu
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGuestAgent simula qemu-guest-agent: ejecuta los procesos y accede a
+// los archivos del host de pruebas
+type fakeGuestAgent struct {
+	mu       sync.Mutex
+	shutdown string // modo recibido en guest-shutdown
+}
+
+func newFakeGuestAgent(t *testing.T, path string) *fakeGuestAgent {
+	t.Helper()
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Error creando el socket del agente: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	agent := &fakeGuestAgent{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go agent.serve(conn)
+		}
+	}()
+	return agent
+}
+
+func (a *fakeGuestAgent) serve(conn net.Conn) {
+	defer conn.Close()
+
+	// Respuesta pendiente de un cliente anterior, que la sincronización descarta
+	conn.Write([]byte(`{"return": {}}` + "\n"))
+
+	type execState struct {
+		polls  int
+		result map[string]any
+	}
+	execs := map[int]*execState{}
+	files := map[int]*os.File{}
+	nextID := 1
+
+	dec := json.NewDecoder(conn)
+	for {
+		var req struct {
+			Execute   string         `json:"execute"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		args := req.Arguments
+
+		var ret any = map[string]any{}
+		switch req.Execute {
+		case "guest-sync-delimited":
+			conn.Write([]byte{0xFF})
+			ret = args["id"]
+
+		case "guest-ping":
+
+		case "guest-exec":
+			var argv []string
+			for _, v := range args["arg"].([]any) {
+				argv = append(argv, v.(string))
+			}
+			cmd := exec.Command(args["path"].(string), argv...)
+			out, _ := cmd.Output()
+			result := map[string]any{
+				"exited":   true,
+				"exitcode": cmd.ProcessState.ExitCode(),
+				"out-data": base64.StdEncoding.EncodeToString(out),
+			}
+			execs[nextID] = &execState{result: result}
+			ret = map[string]any{"pid": nextID}
+			nextID++
+
+		case "guest-exec-status":
+			st := execs[int(args["pid"].(float64))]
+			// La primera consulta informa que el proceso sigue corriendo
+			st.polls++
+			if st.polls == 1 {
+				ret = map[string]any{"exited": false}
+			} else {
+				ret = st.result
+			}
+
+		case "guest-file-open":
+			flag := os.O_RDONLY
+			if args["mode"] == "w" {
+				flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			}
+			f, err := os.OpenFile(args["path"].(string), flag, 0644)
+			if err != nil {
+				ret = nil
+				writeFakeAgent(conn, map[string]any{"error": map[string]any{"class": "GenericError", "desc": err.Error()}})
+				continue
+			}
+			files[nextID] = f
+			ret = nextID
+			nextID++
+
+		case "guest-file-read":
+			f := files[int(args["handle"].(float64))]
+			buf := make([]byte, 5) // trozos pequeños para probar varias lecturas
+			n, _ := f.Read(buf)
+			ret = map[string]any{"count": n, "buf-b64": base64.StdEncoding.EncodeToString(buf[:n]), "eof": n == 0}
+
+		case "guest-file-write":
+			f := files[int(args["handle"].(float64))]
+			data, _ := base64.StdEncoding.DecodeString(args["buf-b64"].(string))
+			n, _ := f.Write(data)
+			ret = map[string]any{"count": n, "eof": false}
+
+		case "guest-file-close":
+			id := int(args["handle"].(float64))
+			files[id].Close()
+			delete(files, id)
+
+		case "guest-network-get-interfaces":
+			ret = []map[string]any{
+				{"name": "lo", "ip-addresses": []map[string]any{
+					{"ip-address-type": "ipv4", "ip-address": "127.0.0.1", "prefix": 8},
+				}},
+				{"name": "eth0", "hardware-address": "52:54:00:12:34:56", "ip-addresses": []map[string]any{
+					{"ip-address-type": "ipv4", "ip-address": "10.0.2.15", "prefix": 24},
+				}},
+			}
+
+		case "guest-shutdown":
+			a.mu.Lock()
+			a.shutdown = args["mode"].(string)
+			a.mu.Unlock()
+			continue // el agente no responde
+
+		default:
+			writeFakeAgent(conn, map[string]any{"error": map[string]any{"class": "CommandNotFound", "desc": req.Execute}})
+			continue
+		}
+		writeFakeAgent(conn, map[string]any{"return": ret})
+	}
+}
+
+func writeFakeAgent(conn net.Conn, v any) {
+	data, _ := json.Marshal(v)
+	conn.Write(append(data, '\n'))
+}
+
+func TestGuestAgent(t *testing.T) {
+	vm := &QemuVM{config: &QemuConfig{GuestAgent: true}, state: StateRunning, workDir: t.TempDir()}
+	fake := newFakeGuestAgent(t, vm.guestAgentSocketPath())
+	t.Cleanup(vm.closeGuestAgent)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	agent, err := vm.GuestAgent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	res, err := agent.Exec(ctx, "sh", []string{"-c", "echo hola"}, nil)
+	if err != nil || res.Stdout != "hola\n" {
+		t.Fatalf("Exec: %+v, %v", res, err)
+	}
+	_, err = agent.Exec(ctx, "sh", []string{"-c", "exit 2"}, nil)
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus != 2 {
+		t.Errorf("Se esperaba *ExitError con código 2, obtenido %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "archivo.txt")
+	if err := agent.WriteFile(ctx, path, []byte("contenido del invitado")); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	data, err := agent.ReadFile(ctx, path)
+	if err != nil || string(data) != "contenido del invitado" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	var agentErr *GuestAgentError
+	if _, err := agent.ReadFile(ctx, filepath.Join(path, "no-existe")); !errors.As(err, &agentErr) {
+		t.Errorf("Se esperaba *GuestAgentError, obtenido %v", err)
+	}
+
+	ips, err := vm.GuestIPs(ctx)
+	if err != nil || len(ips) != 1 || ips[0].String() != "10.0.2.15" {
+		t.Fatalf("GuestIPs: %v, %v", ips, err)
+	}
+
+	if err := agent.Shutdown(ctx, ""); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		fake.mu.Lock()
+		mode := fake.shutdown
+		fake.mu.Unlock()
+		if mode == "powerdown" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("El agente no recibió guest-shutdown, modo %q", mode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGuestAgentDisabled(t *testing.T) {
+	vm := &QemuVM{config: &QemuConfig{}, state: StateRunning, workDir: t.TempDir()}
+	if _, err := vm.GuestAgent(); err == nil {
+		t.Error("Se esperaba error sin WithGuestAgent")
+	}
+
+	// Sin agente en el invitado el comando falla al vencer ctx
+	vm.config.GuestAgent = true
+	ln, err := net.Listen("unix", vm.guestAgentSocketPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	t.Cleanup(vm.closeGuestAgent)
+
+	agent, _ := vm.GuestAgent()
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := agent.Ping(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Se esperaba timeout, obtenido %v", err)
+	}
+}
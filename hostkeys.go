@@ -0,0 +1,208 @@
+package goqemu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// hostKeyPolicy define cómo se verifica la clave de host SSH del invitado
+type hostKeyPolicy string
+
+const (
+	// HostKeyTOFU confía en la primera clave y la guarda. Una clave distinta
+	// se rechaza salvo tras recrear el disco o restaurar un snapshot, en
+	// cuyo caso se aprende la nueva.
+	HostKeyTOFU hostKeyPolicy = "tofu"
+	// HostKeyStrict rechaza siempre una clave distinta de la guardada, para
+	// VMs de larga duración. Solo ForgetHostKey permite aprender otra.
+	HostKeyStrict hostKeyPolicy = "strict"
+	// HostKeyIgnore no verifica la clave de host
+	HostKeyIgnore hostKeyPolicy = "ignore"
+)
+
+// HostKeyError indica que el invitado presentó una clave de host distinta
+// de la guardada en el known_hosts de la VM
+type HostKeyError struct {
+	KnownHostsPath string
+	Got            string   // huella SHA256 de la clave presentada
+	Known          []string // huellas de las claves guardadas
+}
+
+func (e *HostKeyError) Error() string {
+	return fmt.Sprintf("la clave de host SSH de la VM cambió: se obtuvo %s, se esperaba %s (ver %s)",
+		e.Got, strings.Join(e.Known, " o "), e.KnownHostsPath)
+}
+
+// HostKeyChange describe la sustitución de la clave de host guardada
+// después de recrear el disco o restaurar un snapshot
+type HostKeyChange struct {
+	Old []string // huellas SHA256 de las claves sustituidas
+	New string   // huella SHA256 de la clave aprendida
+}
+
+// OnHostKeyChange registra una función que se llama cada vez que la VM
+// aprende una clave de host distinta de la guardada
+func (vm *QemuVM) OnHostKeyChange(fn func(HostKeyChange)) {
+	vm.sshMu.Lock()
+	defer vm.sshMu.Unlock()
+	vm.onHostKeyChange = append(vm.onHostKeyChange, fn)
+}
+
+// knownHostsPath devuelve el known_hosts gestionado por goqemu para la VM
+func (vm *QemuVM) knownHostsPath() string {
+	return filepath.Join(vm.workDir, "known_hosts")
+}
+
+// hostKeyPolicy devuelve la política configurada
+func (vm *QemuVM) hostKeyPolicy() hostKeyPolicy {
+	if vm.config != nil && vm.config.HostKeyPolicy != "" {
+		return vm.config.HostKeyPolicy
+	}
+	return HostKeyTOFU
+}
+
+// ForgetHostKey borra las claves de host guardadas: la próxima conexión
+// confiará en la clave que presente el invitado
+func (vm *QemuVM) ForgetHostKey() error {
+	err := os.Remove(vm.knownHostsPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// invalidateHostKey permite que la próxima conexión aprenda una clave de
+// host distinta porque el invitado pudo cambiarla: disco recreado o
+// snapshot restaurado. En modo estricto no tiene efecto.
+func (vm *QemuVM) invalidateHostKey() {
+	vm.hostKeyRelearn.Store(true)
+}
+
+// knownHostKeys lee las claves guardadas para la VM
+func (vm *QemuVM) knownHostKeys() ([]ssh.PublicKey, error) {
+	data, err := os.ReadFile(vm.knownHostsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []ssh.PublicKey
+	for len(data) > 0 {
+		_, _, key, _, rest, err := ssh.ParseKnownHosts(data)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("known_hosts inválido %s: %v", vm.knownHostsPath(), err)
+		}
+		keys = append(keys, key)
+		data = rest
+	}
+	return keys, nil
+}
+
+// writeKnownHostKeys guarda las claves de host de la VM. El nombre de host
+// es el id de la VM porque el puerto SSH cambia en cada arranque; el archivo
+// sirve a ssh con -o HostKeyAlias=<id>.
+func (vm *QemuVM) writeKnownHostKeys(keys []ssh.PublicKey) error {
+	alias := vm.id
+	if alias == "" {
+		alias = "goqemu"
+	}
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(knownhosts.Line([]string{alias}, key))
+		b.WriteString("\n")
+	}
+	return os.WriteFile(vm.knownHostsPath(), []byte(b.String()), 0600)
+}
+
+// hostKeyAlgorithms devuelve los tipos de las claves guardadas para que el
+// invitado presente una de ellas y no otra clave válida de distinto tipo
+func (vm *QemuVM) hostKeyAlgorithms() []string {
+	policy := vm.hostKeyPolicy()
+	if policy == HostKeyIgnore || (vm.hostKeyRelearn.Load() && policy != HostKeyStrict) {
+		return nil
+	}
+	keys, err := vm.knownHostKeys()
+	if err != nil {
+		return nil
+	}
+
+	var algos []string
+	for _, key := range keys {
+		switch key.Type() {
+		case ssh.KeyAlgoRSA:
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algos = append(algos, key.Type())
+		}
+	}
+	return algos
+}
+
+// hostKeyCallback verifica la clave de host del invitado según la política
+func (vm *QemuVM) hostKeyCallback() ssh.HostKeyCallback {
+	if vm.hostKeyPolicy() == HostKeyIgnore {
+		return ssh.InsecureIgnoreHostKey()
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		known, err := vm.knownHostKeys()
+		if err != nil {
+			return err
+		}
+
+		for _, k := range known {
+			if bytes.Equal(k.Marshal(), key.Marshal()) {
+				vm.hostKeyRelearn.Store(false)
+				return nil
+			}
+		}
+
+		// Primera conexión: confiar en la clave y guardarla
+		if len(known) == 0 {
+			return vm.writeKnownHostKeys([]ssh.PublicKey{key})
+		}
+
+		var fingerprints []string
+		for _, k := range known {
+			fingerprints = append(fingerprints, ssh.FingerprintSHA256(k))
+		}
+
+		if vm.hostKeyRelearn.Load() && vm.hostKeyPolicy() != HostKeyStrict {
+			vm.hostKeyRelearn.Store(false)
+			if err := vm.writeKnownHostKeys([]ssh.PublicKey{key}); err != nil {
+				return err
+			}
+			vm.notifyHostKeyChange(HostKeyChange{Old: fingerprints, New: ssh.FingerprintSHA256(key)})
+			return nil
+		}
+
+		return &HostKeyError{KnownHostsPath: vm.knownHostsPath(), Got: ssh.FingerprintSHA256(key), Known: fingerprints}
+	}
+}
+
+// notifyHostKeyChange llama a las funciones registradas con OnHostKeyChange
+func (vm *QemuVM) notifyHostKeyChange(change HostKeyChange) {
+	vm.sshMu.Lock()
+	hooks := make([]func(HostKeyChange), len(vm.onHostKeyChange))
+	copy(hooks, vm.onHostKeyChange)
+	vm.sshMu.Unlock()
+
+	for _, fn := range hooks {
+		fn(change)
+	}
+}
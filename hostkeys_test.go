@@ -0,0 +1,86 @@
+package goqemu
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+)
+
+// connectTo conecta la VM al servidor SSH de prueba indicado
+func connectTo(t *testing.T, vm *QemuVM, srv *fakeSSHServer) error {
+	t.Helper()
+
+	_, portStr, _ := net.SplitHostPort(srv.addr)
+	vm.sshPort, _ = strconv.Atoi(portStr)
+
+	signer, err := vm.loadSSHKey()
+	if err != nil {
+		t.Fatalf("Error generando clave: %v", err)
+	}
+	srv.authorize(signer.PublicKey())
+
+	vm.closeSSH()
+	err = vm.connectSSH()
+	if err == nil {
+		t.Cleanup(vm.closeSSH)
+	}
+	return err
+}
+
+func TestHostKeyTOFU(t *testing.T) {
+	vm := &QemuVM{config: &QemuConfig{}, state: StateRunning, workDir: t.TempDir(), id: "vm-test"}
+	original, recreated := newFakeSSHServer(t), newFakeSSHServer(t)
+
+	var changes []HostKeyChange
+	vm.OnHostKeyChange(func(c HostKeyChange) { changes = append(changes, c) })
+
+	if err := connectTo(t, vm, original); err != nil {
+		t.Fatalf("Error en la primera conexión: %v", err)
+	}
+	keys, _ := vm.knownHostKeys()
+	if len(keys) != 1 {
+		t.Fatalf("Se esperaba una clave guardada, obtenidas %d", len(keys))
+	}
+
+	// Otra clave sin motivo se rechaza
+	err := connectTo(t, vm, recreated)
+	var hostErr *HostKeyError
+	if !errors.As(err, &hostErr) {
+		t.Fatalf("Se esperaba *HostKeyError, obtenido: %v", err)
+	}
+
+	// Tras restaurar un snapshot o recrear el disco se aprende la nueva
+	vm.invalidateHostKey()
+	if err := connectTo(t, vm, recreated); err != nil {
+		t.Fatalf("Error tras invalidar la clave: %v", err)
+	}
+	if len(changes) != 1 || len(changes[0].Old) != 1 || changes[0].Old[0] != hostErr.Known[0] || changes[0].New != hostErr.Got {
+		t.Errorf("Se esperaba un aviso del cambio de clave, obtenidos: %+v", changes)
+	}
+	if err := connectTo(t, vm, original); err == nil {
+		t.Error("La clave anterior debería haberse sustituido")
+	}
+}
+
+func TestHostKeyStrict(t *testing.T) {
+	vm := &QemuVM{config: &QemuConfig{HostKeyPolicy: HostKeyStrict}, state: StateRunning, workDir: t.TempDir(), id: "vm-test"}
+	original, recreated := newFakeSSHServer(t), newFakeSSHServer(t)
+
+	if err := connectTo(t, vm, original); err != nil {
+		t.Fatalf("Error en la primera conexión: %v", err)
+	}
+
+	vm.invalidateHostKey()
+	var hostErr *HostKeyError
+	if err := connectTo(t, vm, recreated); !errors.As(err, &hostErr) {
+		t.Fatalf("El modo estricto debería rechazar la clave nueva, obtenido: %v", err)
+	}
+
+	if err := vm.ForgetHostKey(); err != nil {
+		t.Fatalf("Error olvidando la clave: %v", err)
+	}
+	if err := connectTo(t, vm, recreated); err != nil {
+		t.Fatalf("Error tras ForgetHostKey: %v", err)
+	}
+}
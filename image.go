@@ -1,6 +1,7 @@
 package goqemu
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -42,3 +43,33 @@ func downloadImage(url, dest string) error {
 	_, err = io.Copy(out, resp.Body)
 	return err
 }
+
+// getDiskPath devuelve la ruta del disco propio de la VM con directorio dir
+func getDiskPath(dir string) string {
+	return filepath.Join(dir, "disk.qcow2")
+}
+
+// createOverlay crea en disk un disco qcow2 de sizeGB que usa la imagen
+// base como respaldo de solo lectura. Cada VM escribe en su propio disco y
+// varias VMs pueden compartir la misma imagen descargada.
+func createOverlay(runner Runner, base, disk string, sizeGB int) error {
+	_, err := runner.Output("qemu-img", "create", "-f", "qcow2", "-F", "qcow2", "-b", base, disk, fmt.Sprintf("%dG", sizeGB))
+	return err
+}
+
+// ensureDisk crea el directorio de trabajo dir y el disco de la VM si no
+// existen. Devuelve true si el disco es nuevo.
+func ensureDisk(runner Runner, config *QemuConfig, dir string) (bool, error) {
+	disk := getDiskPath(dir)
+	if _, err := os.Stat(disk); !os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, err
+	}
+	if err := createOverlay(runner, getImagePath(config.ImageURL), disk, config.DiskSize); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -1,13 +1,17 @@
 package goqemu
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -21,73 +25,162 @@ const (
 	DisplayVNC  vmDisplay = "vnc"
 )
 
-// QemuConfig define la configuración básica de una VM
+// QemuConfig define la configuración básica de una VM. Los campos con valor
+// cero toman los valores de DefaultConfig.
 type QemuConfig struct {
+	Name              string // nombre de la VM, opcional: se usa como id y su directorio persiste entre ejecuciones
 	RAM               int    // GB, default 4
 	CPU               int    // cores, default 2
 	DiskSize          int    // GB, default 10
 	ImageURL          string // opcional, default debian 12
 	SnapshotsInMemory bool
-	Display           vmDisplay // "none", "gtk", "sdl", "vnc"
+	Display           vmDisplay // "none", "gtk" (default), "sdl", "vnc"
 	VNCPort           int       // Puerto VNC si Display = "vnc"
+	Machine           string    // tipo de máquina (-machine), opcional: pc, q35...
+	Accel             vmAccel   // "auto" (default), "kvm" o "tcg"
+
+	ShutdownTimeout time.Duration // gracia para el apagado ACPI antes de forzar, default 30s
+	PortForwards    []PortForward // reenvíos adicionales de puertos del host al invitado
+	NICs            []NIC         // tarjetas de red, default una de red de usuario en 10.0.2.0/24
+	CommandTimeout  time.Duration // tiempo máximo por comando en SendCommand, default 5m
+
+	MaxConcurrentCommands int // comandos de Submit ejecutados a la vez, default 4
+
+	SSHUser     string // usuario SSH del invitado, default "root"
+	SSHKeyPath  string // clave privada a usar, default una ed25519 generada en el directorio de la VM
+	SSHPassword string // contraseña opcional, se usa si falla la clave
+
+	KeepaliveInterval time.Duration // intervalo entre keepalives SSH, default 15s
+	ReconnectTimeout  time.Duration // tiempo máximo para reconectar SSH, default 60s
+
+	HostKeyPolicy hostKeyPolicy // verificación de la clave de host: "tofu" (default), "strict" o "ignore"
+
+	ReadyTimeout time.Duration // tiempo máximo de Start para que la VM esté lista, default 2m
+	ReadyProbes  []Probe       // sondas que Start espera tras el handshake SSH
+	ConsoleOnly  bool          // Start no espera SSH: la VM se maneja con Console
+
+	GuestAgent bool // añade el canal virtio-serial de qemu-guest-agent
+
+	Runner Runner // ejecuta los binarios de QEMU, default ExecRunner (os/exec)
 }
 
 // QemuVM representa una instancia de máquina virtual
 type QemuVM struct {
-	config      *QemuConfig
-	ip          string
-	sshClient   *ssh.Client
-	commandChan chan SshCommand
-	process     *os.Process
-	defaultArgs []string // Argumentos de inicialización por defecto
+	config    *QemuConfig
+	ip        string // IP del invitado en la red de usuario, p. ej. 10.0.2.15
+	sshPort   int    // puerto del host reenviado al 22 del invitado
+	sshClient *ssh.Client
+	sshSigner ssh.Signer // clave privada con la que se autentica la VM
+
+	sshMu           sync.Mutex    // protege sshClient, reconnecting y los hooks
+	reconnecting    chan struct{} // semáforo que evita reconexiones simultáneas
+	onReconnect     []func(ReconnectEvent)
+	onHostKeyChange []func(HostKeyChange)
+
+	forwardsMu sync.Mutex // protege forwards
+	forwards   map[*Forward]struct{}
+
+	attached       bool        // obtenida con Attach: el proceso QEMU pertenece a otro programa
+	hostKeyRelearn atomic.Bool // el invitado pudo cambiar de clave de host: disco nuevo o snapshot restaurado
+	process        Process     // proceso de la ventana gráfica abierta con OpenWindow
+	defaultArgs    []string    // Argumentos de inicialización por defecto
 
-	cmd *exec.Cmd
+	id      string     // identificador único de la VM
+	workDir string     // directorio de trabajo: sockets, logs, pid
+	qmp     *QMPClient // conexión al monitor QMP, nil si la VM no está corriendo
+
+	runner         Runner        // ejecuta qemu-system-x86_64, qemu-img...
+	caps           *Capabilities // capacidades del binario de QEMU
+	accel          vmAccel       // acelerador elegido: kvm o tcg
+	proc           Process       // proceso QEMU principal de esta VM
+	exited         chan struct{} // se cierra al terminar QEMU si es proceso hijo
+	exitStatus     ExitStatus    // estado de salida de la última ejecución
+	shutdownReason string        // motivo del evento QMP SHUTDOWN
+
+	mu    sync.Mutex // protege state, el estado de salida del proceso y qmp
+	state VMState
+
+	consoleMu sync.Mutex // protege console
+	console   *Console   // conexión con el puerto serie abierta con Console
+
+	portsMu        sync.Mutex    // protege ports y allocatedPorts
+	ports          []PortForward // reenvíos de puertos activos, nil si la VM no está corriendo
+	allocatedPorts []int         // puertos del host reservados automáticamente
+
+	agentMu sync.Mutex  // protege agent
+	agent   *GuestAgent // cliente de qemu-guest-agent, nil hasta usarlo
+
+	queueMu sync.RWMutex  // protege queue
+	queue   *commandQueue // cola de comandos asíncronos, nil si no está corriendo
 
 	ctx    context.Context
 	cancel context.CancelFunc
 }
 
-// NewQemuVM crea una nueva instancia de QemuVM
+// New crea una nueva instancia de QemuVM a partir de opciones. Los campos
+// no indicados toman los valores de DefaultConfig y todos los problemas de
+// validación se devuelven juntos en un *ConfigError.
+func New(opts ...Option) (*QemuVM, error) {
+	var config QemuConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return newVM(config)
+}
+
+// NewQemuVM crea una nueva instancia de QemuVM. Sin configuración, o con
+// campos vacíos, se usan los valores de DefaultConfig. La configuración
+// recibida se copia y nunca se modifica.
 func NewQemuVM(configs ...*QemuConfig) (*QemuVM, error) {
-	var config *QemuConfig
+	var config QemuConfig
+	if len(configs) > 0 && configs[0] != nil {
+		config = *configs[0]
+	}
+	return newVM(config)
+}
 
-	if err := checkQemuInstalled(); err != nil {
-		return nil, err
+// newVM valida la configuración, rellenada con los valores por defecto,
+// y prepara la VM sin lanzar QEMU
+func newVM(input QemuConfig) (*QemuVM, error) {
+	cfg := input.withDefaults()
+	config := &cfg
+
+	errs := &ConfigError{}
+	config.validate(errs)
+
+	runner := config.Runner
+	if runner == nil {
+		runner = ExecRunner{}
 	}
 
-	// Si no se proporciona configuración o es nil, crear una por defecto
-	if len(configs) == 0 || configs[0] == nil {
-		config = &QemuConfig{
-			RAM:      4,
-			CPU:      2,
-			DiskSize: 10,
-			Display:  DisplayGTK,
-			// Display:  DisplaySDL,
-		}
+	// Validar contra lo que soporta el binario instalado antes de lanzar
+	// nada. Sin un QEMU válido se informa junto al resto de problemas.
+	caps, err := checkQemuInstalled(runner)
+	if err != nil {
+		errs.add("QEMU", "%v", err)
 	} else {
-		config = configs[0]
-		// Validar valores mínimos
-		if config.RAM < 1 {
-			return nil, errors.New("RAM debe ser al menos 1GB")
-		}
-		if config.CPU < 1 {
-			return nil, errors.New("CPU debe ser al menos 1 core")
-		}
-		if config.DiskSize < 1 {
-			return nil, errors.New("tamaño de disco debe ser al menos 1GB")
-		}
-		// Si no se especifica display, usar gtk por defecto
-		if config.Display == "" {
-			config.Display = DisplaySDL
-		}
+		validateCapabilities(config, caps, errs)
 	}
 
-	// Crear instancia con valores por defecto si es necesario
-	if config.ImageURL == "" {
-		config.ImageURL = "https://cloud.debian.org/images/cloud/bookworm/daily/latest/debian-12-nocloud-amd64-daily.qcow2"
+	// Elegir acelerador: KVM si está disponible, TCG en su defecto
+	accel, err := selectAccel(config.Accel, caps)
+	if err != nil {
+		errs.add("Accel", "%v", err)
+	}
+
+	// Un nombre fijo no puede estar en uso por otra VM en ejecución
+	id := config.Name
+	if id == "" {
+		id = generateVMID()
+	} else if _, err := readSession(getVMDir(id)); err == nil {
+		errs.add("Name", "ya hay una VM en ejecución llamada %q", id)
+	}
+
+	if err := errs.errOrNil(); err != nil {
+		return nil, err
 	}
 
-	// Crear imagen de disco si no existe
+	// Descargar la imagen base si no existe
 	imgPath := getImagePath(config.ImageURL)
 	if _, err := os.Stat(imgPath); os.IsNotExist(err) {
 		err := downloadImage(config.ImageURL, imgPath)
@@ -96,177 +189,439 @@ func NewQemuVM(configs ...*QemuConfig) (*QemuVM, error) {
 		}
 	}
 
-	// Asignar IP a la VM
-	ip, mask, err := assignVMIP()
+	// Crear directorios necesarios
+	err = createQemuDirs()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error creando directorios: %v", err)
 	}
 
-	fmt.Printf("IP asignada: %s mascara %v \n", ip, mask)
-
-	netConfig := fmt.Sprintf("user,id=net0,net=%s,dhcpstart=%s,hostfwd=tcp::2222-:22", mask, ip)
+	workDir, err := createVMDir(id)
+	if err != nil {
+		return nil, fmt.Errorf("error creando directorio de la VM: %v", err)
+	}
 
-	defaultArgs := []string{
-		"-m", fmt.Sprintf("%dG", config.RAM),
-		"-smp", fmt.Sprintf("%d", config.CPU),
-		"-hda", imgPath,
-		"-netdev", netConfig,
-		"-device", "e1000,netdev=net0",
+	// Disco propio de la VM sobre la imagen base. Una VM con nombre
+	// conserva su disco entre ejecuciones.
+	diskPath := getDiskPath(workDir)
+	diskCreated, err := ensureDisk(runner, config, workDir)
+	if err != nil {
+		return nil, fmt.Errorf("error creando disco de la VM: %v", err)
 	}
 
-	// Configurar interfaz gráfica
-	switch config.Display {
-	case "gtk":
-		defaultArgs = append(defaultArgs, "-display", "gtk")
-	case "sdl":
-		defaultArgs = append(defaultArgs, "-display", "sdl")
-	case "vnc":
-		if config.VNCPort == 0 {
-			return nil, fmt.Errorf("se requiere un puerto VNC válido")
-		}
-		defaultArgs = append(defaultArgs,
-			"-vnc", fmt.Sprintf(":%d", config.VNCPort-5900),
-			"-display", "none")
-	default:
-		defaultArgs = append(defaultArgs, "-display", "none")
+	// IP que el DHCP de la red de usuario entrega al invitado
+	ip := ""
+	if i := userNIC(config.NICs); i >= 0 {
+		ip = userNetGuestIP(config.NICs[i].Subnet)
 	}
 
+	fmt.Printf("IP asignada: %s\n", ip)
+
 	// Crear estructura QemuVM
 	vm := &QemuVM{
 		config:      config,
 		ip:          ip,
-		commandChan: make(chan SshCommand, 100), // Buffer de 100 comandos
-		defaultArgs: defaultArgs,
+		defaultArgs: baseArgs(config, diskPath, accel),
+		id:          id,
+		workDir:     workDir,
+		state:       StateCreated,
+		runner:      runner,
+		caps:        caps,
+		accel:       accel,
+	}
+
+	// Un disco nuevo genera claves de host nuevas en el primer arranque
+	if diskCreated {
+		vm.invalidateHostKey()
 	}
 
 	// Create context with cancel
 	vm.ctx, vm.cancel = context.WithCancel(context.Background())
 
-	// Crear directorios necesarios
-	err = createQemuDirs()
-	if err != nil {
-		return nil, fmt.Errorf("error creando directorios: %v", err)
-	}
-
 	return vm, nil
 }
 
-// Start inicia la máquina virtual
+// Start inicia la máquina virtual. Se puede volver a llamar después de Stop
+// o de una caída sin crear una nueva VM.
 func (vm *QemuVM) Start() error {
+	return vm.start("Start", false)
+}
+
+// start arranca el proceso principal de QEMU, con ventana gráfica si
+// window es true
+func (vm *QemuVM) start(op string, window bool) error {
 	// Validar configuración mínima
 	if vm.config == nil {
 		return errors.New("configuración no inicializada")
 	}
 
-	// Verificar disponibilidad del puerto SSH
-	if !isPortAvailable(2222) {
-		return fmt.Errorf("el puerto 2222 ya está en uso")
+	from, err := vm.transition(op, StateStarting)
+	if err != nil {
+		return err
+	}
+
+	// Tras una caída quedan la cola, los túneles y las conexiones del
+	// proceso anterior
+	if from == StateCrashed {
+		vm.releaseResources()
+	}
+
+	// Recrear el contexto si la VM se detuvo anteriormente
+	if vm.ctx.Err() != nil {
+		vm.ctx, vm.cancel = context.WithCancel(context.Background())
+	}
+
+	// Una VM sin nombre pierde su disco al detenerse: arrancar con uno nuevo
+	diskCreated, err := ensureDisk(vm.runnerOrDefault(), vm.config, vm.workDir)
+	if err != nil {
+		vm.setState(StateStopped)
+		return fmt.Errorf("error creando disco de la VM: %v", err)
+	}
+	if diskCreated {
+		vm.invalidateHostKey()
+	}
+
+	err = vm.launch(window)
+	if err != nil {
+		// Liberar el proceso si llegó a lanzarse para permitir reintentar
+		vm.stopProcess(0)
+		vm.releaseResources()
+		if vm.Status() != StateCrashed {
+			vm.setState(StateStopped)
+		}
+		return err
+	}
+
+	vm.startQueue()
+	vm.setState(StateRunning)
+	return nil
+}
+
+// launch lanza QEMU y espera a que la VM esté lista
+func (vm *QemuVM) launch(window bool) error {
+	// Reservar un puerto libre del host para SSH
+	err := vm.allocateSSHPort()
+	if err != nil {
+		return err
+	}
+	if err := vm.allocatePortForwards(); err != nil {
+		return err
 	}
 
 	args := make([]string, len(vm.defaultArgs))
 	copy(args, vm.defaultArgs)
+	args = append(args, displayArgs(vm.config, window)...)
+	args = append(args, vm.netArgs(true)...)
 
-	// Solo daemonizar si no hay interfaz gráfica
-	if vm.config.Display == "none" {
-		args = append(args, "-daemonize")
+	// Entregar la clave pública SSH al invitado
+	provision, err := vm.provisionArgs()
+	if err != nil {
+		return err
 	}
+	args = append(args, provision...)
 
-	cmd := exec.Command("qemu-system-x86_64", args...)
-	err := cmd.Start()
+	// Monitor QMP y puerto serie en sockets unix propios de la VM
+	args = append(args, vm.qmpArgs()...)
+	args = append(args, vm.serialArgs()...)
+	args = append(args, vm.guestAgentArgs()...)
+
+	// Registrar el pid para detener solo este proceso
+	os.Remove(vm.pidFilePath())
+	args = append(args, "-pidfile", vm.pidFilePath())
+
+	// QEMU se ejecuta como proceso hijo, sin -daemonize, para poder
+	// supervisarlo y conocer su estado de salida. La salida va al log de la VM.
+	logFile, err := os.Create(vm.logFilePath())
 	if err != nil {
-		return fmt.Errorf("error iniciando QEMU: %v", err)
+		return fmt.Errorf("error creando log de QEMU: %v", err)
 	}
 
-	// Esperar a que el servicio SSH esté disponible
-	err = waitForSSH(vm.ip, 2222, 30)
+	proc, err := vm.runnerOrDefault().Start("qemu-system-x86_64", args, logFile, logFile)
 	if err != nil {
-		return fmt.Errorf("error esperando SSH: %v", err)
+		logFile.Close()
+		return fmt.Errorf("error iniciando QEMU: %v", err)
 	}
 
-	// Establecer conexión SSH
-	err = vm.connectSSH()
+	exited := vm.setProcess(proc)
+	go vm.supervise(proc, logFile, exited)
+
+	// Abortar la espera si QEMU termina durante el arranque
+	ctx, cancel := context.WithCancel(vm.ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-exited:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Conectar con el monitor QMP
+	waitCtx, waitCancel := context.WithTimeout(ctx, 30*time.Second)
+	defer waitCancel()
+	qmp, err := DialQMPContext(waitCtx, vm.qmpSocketPath())
 	if err != nil {
-		return fmt.Errorf("error conectando SSH: %v", err)
+		return vm.startError("error conectando con el monitor QMP", err)
+	}
+	qmp.setEventHandler(vm.handleQMPEvent)
+	vm.mu.Lock()
+	vm.qmp = qmp
+	vm.mu.Unlock()
+
+	// Esperar al handshake SSH y a las sondas configuradas. Sin SSH la VM
+	// se maneja por la consola serie.
+	if !vm.config.ConsoleOnly {
+		readyCtx, readyCancel := context.WithTimeout(ctx, vm.readyTimeout())
+		defer readyCancel()
+		probes := append([]Probe{ProbeSSH()}, vm.config.ReadyProbes...)
+		if err := vm.WaitReady(readyCtx, probes...); err != nil {
+			return vm.startError("error esperando a que la VM esté lista", err)
+		}
+	}
+
+	// Publicar la conexión para Attach desde otros procesos
+	if err := vm.writeSession(); err != nil {
+		return fmt.Errorf("error guardando sesión de la VM: %v", err)
 	}
 
 	return nil
 }
 
-// Stop detiene la máquina virtual
+// Stop detiene la máquina virtual usando el tiempo de gracia configurado
+// en ShutdownTimeout. Una VM sin nombre borra su directorio de trabajo y
+// su disco; el siguiente Start parte de la imagen base.
 func (vm *QemuVM) Stop() error {
+	grace := defaultShutdownTimeout
+	if vm.config != nil && vm.config.ShutdownTimeout > 0 {
+		grace = vm.config.ShutdownTimeout
+	}
 
-	// Cerrar conexión SSH si está abierta
-	if vm.sshClient != nil {
-		err := vm.sshClient.Close()
-		if err != nil {
-			return fmt.Errorf("error cerrando conexión SSH: %v", err)
-		}
+	_, err := vm.Shutdown(grace)
+	return err
+}
+
+// Shutdown detiene solo el proceso QEMU de esta VM: solicita un apagado ACPI,
+// espera grace y luego escala a quit, SIGTERM y SIGKILL.
+// Devuelve el método con el que terminó el proceso.
+func (vm *QemuVM) Shutdown(grace time.Duration) (StopMethod, error) {
+	if vm.attached {
+		return "", ErrAttached
 	}
 
-	// Buscar y matar el proceso QEMU
-	cmd := exec.Command("pkill", "qemu-system-x86_64")
-	err := cmd.Run()
+	from, err := vm.transition("Stop", StateShuttingDown)
 	if err != nil {
-		return fmt.Errorf("error deteniendo QEMU: %v", err)
+		return "", err
 	}
 
-	// Liberar recursos
-	vm.config = nil
-	vm.ip = ""
-	close(vm.commandChan)
+	// Resolver los comandos encolados y cerrar los túneles antes de
+	// cerrar la conexión
+	vm.stopQueue()
+	vm.closeForwards()
 
+	// Cerrar conexión SSH si está abierta. Un error aquí no debe impedir
+	// detener QEMU, la conexión puede estar ya rota.
+	vm.closeSSH()
+
+	// Un invitado pausado no puede atender el apagado ACPI
+	if from == StatePaused {
+		grace = 0
+	}
+
+	// Detener el proceso QEMU propio de la VM
+	method, err := vm.stopProcess(grace)
+	if err != nil {
+		// La VM sigue corriendo: volver a aceptar comandos
+		if from == StateRunning || from == StatePaused {
+			vm.startQueue()
+		}
+		vm.setState(from)
+		return method, fmt.Errorf("error deteniendo QEMU: %v", err)
+	}
+
+	vm.finishStop()
+	return method, nil
+}
+
+// finishStop libera los recursos de la VM una vez terminado el proceso
+// QEMU y la deja detenida
+func (vm *QemuVM) finishStop() {
+	vm.releaseResources()
 	vm.cancel() // Cancel context to stop goroutine
 
-	return nil
+	// Una VM sin nombre no conserva su directorio ni su disco
+	if vm.config.Name == "" {
+		os.RemoveAll(vm.workDir)
+	}
+
+	vm.setState(StateStopped)
 }
 
-// OpenWindow abre la ventana gráfica de QEMU
-func (vm *QemuVM) OpenWindow() error {
-	vm.cmd = exec.Command("qemu-system-x86_64", vm.defaultArgs...)
+// releaseResources cierra las conexiones con QEMU una vez terminado el proceso
+func (vm *QemuVM) releaseResources() {
+	vm.stopQueue()
+	vm.closeForwards()
+
+	vm.closeSSH()
+	vm.closeConsole()
+	vm.closeGuestAgent()
+
+	// Cerrar conexión con el monitor QMP
+	vm.mu.Lock()
+	qmp := vm.qmp
+	vm.qmp = nil
+	vm.mu.Unlock()
+	if qmp != nil {
+		qmp.Close()
+	}
 
-	// Create pipe for stderr
-	stderr, err := vm.cmd.StderrPipe()
-	if err != nil {
-		return err
+	// Cerrar la ventana gráfica lanzada con OpenWindow
+	if vm.process != nil {
+		vm.process.Kill()
+		vm.process = nil
 	}
 
-	// Start the process
-	err = vm.cmd.Start()
+	vm.proc = nil
+	os.Remove(vm.pidFilePath())
+	os.Remove(vm.sessionFilePath())
+
+	releasePort(vm.sshPort)
+	vm.sshPort = 0
+	vm.releasePortForwards()
+}
+
+// setProcess registra el proceso QEMU principal de una nueva ejecución
+// y devuelve el canal que se cerrará cuando termine
+func (vm *QemuVM) setProcess(proc Process) chan struct{} {
+	exited := make(chan struct{})
+	vm.mu.Lock()
+	vm.proc = proc
+	vm.exited = exited
+	vm.shutdownReason = ""
+	vm.exitStatus = ExitStatus{}
+	vm.mu.Unlock()
+	return exited
+}
+
+// startError construye el error de arranque. Si QEMU terminó durante
+// el arranque se devuelve el error de caída con el log de QEMU.
+func (vm *QemuVM) startError(msg string, err error) error {
+	select {
+	case <-vm.Done():
+		vm.mu.Lock()
+		crash := vm.exitStatus.Err
+		vm.mu.Unlock()
+		if crash != nil {
+			return crash
+		}
+	default:
+	}
+	return fmt.Errorf("%s: %v", msg, err)
+}
+
+// Capabilities devuelve las capacidades del binario de QEMU usado por la VM
+func (vm *QemuVM) Capabilities() *Capabilities {
+	return vm.caps
+}
+
+// SSHPort devuelve el puerto del host reenviado al SSH del invitado,
+// 0 si la VM no está en ejecución
+func (vm *QemuVM) SSHPort() int {
+	return vm.sshPort
+}
+
+// allocateSSHPort reserva un puerto libre del host para el reenvío SSH
+func (vm *QemuVM) allocateSSHPort() error {
+	if vm.sshPort != 0 {
+		return nil
+	}
+	port, err := allocatePort()
 	if err != nil {
-		return fmt.Errorf("error iniciando QEMU: %v", err)
+		return fmt.Errorf("error reservando puerto SSH: %v", err)
 	}
+	vm.sshPort = port
+	return nil
+}
 
-	// Filter stderr in a goroutine with context
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			select {
-			case <-vm.ctx.Done():
-				return
-			default:
-				line := scanner.Text()
-
-				switch {
-				case strings.Contains(line, "WARNING"):
-					// Ignorar líneas con "WARNING"
-					continue
-				case strings.Contains(line, "pixbuf"):
-					// ignorar pixbuf loaders or the mime database could not be found
-					continue
-				default:
-					fmt.Fprintln(os.Stderr, line)
-				}
-
-			}
+// QMP devuelve el cliente del monitor QMP de la VM, nil si no está corriendo
+func (vm *QemuVM) QMP() *QMPClient {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.qmp
+}
+
+// qmpSocketPath devuelve la ruta del socket QMP de la VM
+func (vm *QemuVM) qmpSocketPath() string {
+	return filepath.Join(vm.workDir, "qmp.sock")
+}
+
+// qmpArgs devuelve los argumentos de QEMU para exponer el monitor QMP
+func (vm *QemuVM) qmpArgs() []string {
+	return []string{"-qmp", fmt.Sprintf("unix:%s,server=on,wait=off", qemuOptValue(vm.qmpSocketPath()))}
+}
+
+// OpenWindow abre la ventana gráfica de QEMU. Si la VM no estaba en
+// ejecución se arranca como con Start, pero con ventana, y queda en
+// estado Running.
+func (vm *QemuVM) OpenWindow() error {
+	if vm.Status() != StateRunning {
+		if err := vm.start("OpenWindow", true); err != nil {
+			return err
 		}
-	}()
+		// La ventana es el proceso principal de la VM
+		vm.mu.Lock()
+		vm.process = vm.proc
+		vm.mu.Unlock()
+		return nil
+	}
 
-	// Guardar el proceso para poder detenerlo luego
-	vm.process = vm.cmd.Process
+	// Una segunda ventana sobre una VM en ejecución no reenvía SSH
+	args := make([]string, len(vm.defaultArgs))
+	copy(args, vm.defaultArgs)
+	args = append(args, displayArgs(vm.config, true)...)
+	args = append(args, vm.netArgs(false)...)
+
+	proc, err := vm.runnerOrDefault().Start("qemu-system-x86_64", args, nil, &stderrFilter{out: os.Stderr})
+	if err != nil {
+		return fmt.Errorf("error iniciando QEMU: %v", err)
+	}
 
+	// Guardar el proceso para poder detenerlo luego
+	vm.process = proc
+	go proc.Wait()
 	return nil
 }
 
+// stderrFilter reenvía la salida de error de QEMU a out omitiendo avisos
+// irrelevantes
+type stderrFilter struct {
+	out io.Writer
+	buf []byte
+}
+
+func (f *stderrFilter) Write(p []byte) (int, error) {
+	f.buf = append(f.buf, p...)
+	for {
+		i := bytes.IndexByte(f.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(f.buf[:i])
+		f.buf = f.buf[i+1:]
+
+		switch {
+		case strings.Contains(line, "WARNING"):
+			// Ignorar líneas con "WARNING"
+			continue
+		case strings.Contains(line, "pixbuf"):
+			// ignorar pixbuf loaders or the mime database could not be found
+			continue
+		default:
+			fmt.Fprintln(f.out, line)
+		}
+	}
+
+	return len(p), nil
+}
+
 // StartWithGUI inicia la VM con interfaz gráfica
 func (vm *QemuVM) StartWithGUI() error {
 	err := vm.Start()
@@ -284,14 +639,13 @@ func (vm *QemuVM) IsWindowOpen() bool {
 	}
 
 	// Verificar si el proceso sigue activo usando tasklist en Windows
-	cmd := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", vm.process.Pid))
-	output, err := cmd.Output()
+	output, err := vm.runnerOrDefault().Output("tasklist", "/FI", fmt.Sprintf("PID eq %d", vm.process.Pid()))
 	if err != nil {
 		return false
 	}
 
 	// Si el proceso está en la lista, está activo
-	if strings.Contains(string(output), fmt.Sprintf("%d", vm.process.Pid)) {
+	if strings.Contains(string(output), fmt.Sprintf("%d", vm.process.Pid())) {
 		fmt.Println("La ventana de QEMU ya está abierta")
 		return true
 	}
@@ -0,0 +1,221 @@
+package goqemu
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// netBackend es el backend de red de una NIC
+type netBackend string
+
+const (
+	NetUser   netBackend = "user"   // red de usuario (slirp): NAT sin privilegios, permite reenviar puertos
+	NetBridge netBackend = "bridge" // tap conectada a un bridge del host mediante qemu-bridge-helper
+	NetTap    netBackend = "tap"    // interfaz tap existente del host
+	NetSocket netBackend = "socket" // enlace privado entre VMs por un socket TCP o multicast
+)
+
+const (
+	defaultNICModel   = "e1000"       // Tarjeta de red emulada
+	defaultUserSubnet = "10.0.2.0/24" // Red de usuario por defecto de QEMU
+	userNetGuestHost  = 15            // Primera dirección que entrega el DHCP de la red de usuario
+)
+
+// NIC es una tarjeta de red de la VM y el backend al que se conecta
+type NIC struct {
+	Model   string     // modelo de tarjeta: e1000 (default), virtio-net-pci, rtl8139...
+	Backend netBackend // "user" (default), "bridge", "tap" o "socket"
+	MAC     string     // opcional; fuera de la red de usuario se genera una por VM
+
+	Subnet string // user: red en CIDR, default 10.0.2.0/24
+	Bridge string // bridge: bridge del host, p. ej. br0
+	Helper string // bridge: ruta de qemu-bridge-helper, opcional
+	Tap    string // tap: nombre de la interfaz tap
+
+	// socket: la VM que escucha usa Listen y la otra Connect con la misma
+	// dirección; con Mcast todas las VMs del grupo comparten el segmento
+	Listen  string
+	Connect string
+	Mcast   string
+}
+
+// UserNIC devuelve una NIC de red de usuario con la subred indicada,
+// la de QEMU si subnet está vacía
+func UserNIC(subnet string) NIC {
+	return NIC{Backend: NetUser, Subnet: subnet}
+}
+
+// BridgeNIC devuelve una NIC conectada al bridge br del host
+func BridgeNIC(br string) NIC {
+	return NIC{Backend: NetBridge, Bridge: br}
+}
+
+// SocketListenNIC devuelve una NIC que espera en addr (host:puerto) a otra
+// VM creada con SocketConnectNIC(addr)
+func SocketListenNIC(addr string) NIC {
+	return NIC{Backend: NetSocket, Listen: addr}
+}
+
+// SocketConnectNIC devuelve una NIC que se conecta a la VM que escucha en addr
+func SocketConnectNIC(addr string) NIC {
+	return NIC{Backend: NetSocket, Connect: addr}
+}
+
+// withDefaults rellena los campos vacíos de la NIC
+func (n NIC) withDefaults() NIC {
+	if n.Model == "" {
+		n.Model = defaultNICModel
+	}
+	if n.Backend == "" {
+		n.Backend = NetUser
+	}
+	if n.Backend == NetUser && n.Subnet == "" {
+		n.Subnet = defaultUserSubnet
+	}
+	return n
+}
+
+// validate añade a errs los problemas de la NIC
+func (n NIC) validate(field string, errs *ConfigError) {
+	if n.MAC != "" {
+		if _, err := net.ParseMAC(n.MAC); err != nil {
+			errs.add(field, "MAC %q inválida", n.MAC)
+		}
+	}
+
+	switch n.Backend {
+	case NetUser:
+		_, subnet, err := net.ParseCIDR(n.Subnet)
+		if err != nil || subnet.IP.To4() == nil {
+			errs.add(field, "subred %q inválida, use una red IPv4 en CIDR como 10.0.2.0/24", n.Subnet)
+		} else if ones, _ := subnet.Mask.Size(); ones > 24 {
+			errs.add(field, "la subred %s es demasiado pequeña, use /24 o mayor", n.Subnet)
+		}
+
+	case NetBridge:
+		if n.Bridge == "" {
+			errs.add(field, "se requiere el nombre del bridge")
+		}
+
+	case NetTap:
+		if n.Tap == "" {
+			errs.add(field, "se requiere el nombre de la interfaz tap")
+		}
+
+	case NetSocket:
+		set := 0
+		for _, addr := range []string{n.Listen, n.Connect, n.Mcast} {
+			if addr == "" {
+				continue
+			}
+			set++
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				errs.add(field, "dirección %q inválida, use host:puerto", addr)
+			}
+		}
+		if set != 1 {
+			errs.add(field, "indique exactamente una de Listen, Connect o Mcast")
+		}
+
+	default:
+		errs.add(field, "backend desconocido %q, use user, bridge, tap o socket", n.Backend)
+	}
+}
+
+// nics devuelve las NICs de la VM: una de red de usuario si no se configuró ninguna
+func (vm *QemuVM) nics() []NIC {
+	if len(vm.config.NICs) == 0 {
+		return []NIC{UserNIC("").withDefaults()}
+	}
+	return vm.config.NICs
+}
+
+// userNIC devuelve el índice de la primera NIC de red de usuario, la que
+// recibe los reenvíos de puertos, o -1 si no hay ninguna
+func userNIC(nics []NIC) int {
+	for i, n := range nics {
+		if n.Backend == NetUser {
+			return i
+		}
+	}
+	return -1
+}
+
+// userNetGuestIP devuelve la dirección que el DHCP de la red de usuario
+// entrega al invitado, p. ej. 10.0.2.15 en 10.0.2.0/24
+func userNetGuestIP(subnet string) string {
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return ""
+	}
+	ip := ipNet.IP.To4()
+	if ip == nil {
+		return ""
+	}
+	guest := make(net.IP, len(ip))
+	copy(guest, ip)
+	guest[3] += userNetGuestHost
+	return guest.String()
+}
+
+// nicMAC devuelve una MAC estable para la NIC i de la VM id. La de QEMU es
+// la misma en todas las VMs y colisiona en un bridge o socket compartido.
+func nicMAC(id string, i int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", id, i)))
+	return fmt.Sprintf("52:54:00:%02x:%02x:%02x", sum[0], sum[1], sum[2])
+}
+
+// netArgs genera -netdev y -device para cada NIC. Si forwardSSH, la primera
+// NIC de red de usuario reenvía el puerto SSH y los PortForwards, con los
+// puertos del host ya reservados.
+func (vm *QemuVM) netArgs(forwardSSH bool) []string {
+	nics := vm.nics()
+	forwardOn := userNIC(nics)
+
+	var args []string
+	for i, n := range nics {
+		id := fmt.Sprintf("net%d", i)
+
+		var netdev string
+		switch n.Backend {
+		case NetUser:
+			netdev = fmt.Sprintf("user,id=%s,net=%s", id, n.Subnet)
+			if forwardSSH && i == forwardOn {
+				netdev += fmt.Sprintf(",hostfwd=tcp:127.0.0.1:%d-:22", vm.sshPort)
+				for _, pf := range vm.PortForwards() {
+					netdev += ",hostfwd=" + pf.withDefaults().hostfwd()
+				}
+			}
+		case NetBridge:
+			netdev = fmt.Sprintf("bridge,id=%s,br=%s", id, n.Bridge)
+			if n.Helper != "" {
+				netdev += ",helper=" + n.Helper
+			}
+		case NetTap:
+			netdev = fmt.Sprintf("tap,id=%s,ifname=%s,script=no,downscript=no", id, n.Tap)
+		case NetSocket:
+			switch {
+			case n.Listen != "":
+				netdev = fmt.Sprintf("socket,id=%s,listen=%s", id, n.Listen)
+			case n.Connect != "":
+				netdev = fmt.Sprintf("socket,id=%s,connect=%s", id, n.Connect)
+			default:
+				netdev = fmt.Sprintf("socket,id=%s,mcast=%s", id, n.Mcast)
+			}
+		}
+
+		device := fmt.Sprintf("%s,netdev=%s", n.Model, id)
+		mac := n.MAC
+		if mac == "" && n.Backend != NetUser {
+			mac = nicMAC(vm.id, i)
+		}
+		if mac != "" {
+			device += ",mac=" + strings.ToLower(mac)
+		}
+
+		args = append(args, "-netdev", netdev, "-device", device)
+	}
+	return args
+}
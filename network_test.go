@@ -0,0 +1,79 @@
+package goqemu
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNetArgs(t *testing.T) {
+	config := QemuConfig{
+		NICs: []NIC{
+			UserNIC("192.168.77.0/24"),
+			BridgeNIC("br0"),
+			{Model: "virtio-net-pci", Backend: NetSocket, Connect: "127.0.0.1:4444", MAC: "52:54:00:AA:BB:CC"},
+		},
+		PortForwards: []PortForward{{Protocol: "tcp", HostPort: 8080, GuestPort: 80}},
+	}.withDefaults()
+	vm := &QemuVM{config: &config, id: "vm-a", sshPort: 2222}
+
+	cmdline := strings.Join(vm.netArgs(true), " ")
+	for _, want := range []string{
+		"-netdev user,id=net0,net=192.168.77.0/24,hostfwd=tcp:127.0.0.1:2222-:22,hostfwd=tcp:127.0.0.1:8080-:80",
+		"-device e1000,netdev=net0 ",
+		"-netdev bridge,id=net1,br=br0",
+		"-device e1000,netdev=net1,mac=" + nicMAC("vm-a", 1),
+		"-netdev socket,id=net2,connect=127.0.0.1:4444",
+		"-device virtio-net-pci,netdev=net2,mac=52:54:00:aa:bb:cc",
+	} {
+		if !strings.Contains(cmdline, want) {
+			t.Errorf("Falta %q en: %s", want, cmdline)
+		}
+	}
+
+	// Otra VM en el mismo bridge recibe otra MAC
+	if nicMAC("vm-a", 1) == nicMAC("vm-b", 1) {
+		t.Error("Dos VMs no deberían compartir MAC")
+	}
+	if ip := userNetGuestIP("192.168.77.0/24"); ip != "192.168.77.15" {
+		t.Errorf("IP del invitado esperada 192.168.77.15, obtenida %s", ip)
+	}
+
+	// Sin NICs configuradas se usa la red de usuario de QEMU
+	vm = &QemuVM{config: &QemuConfig{}, sshPort: 2222}
+	if got := strings.Join(vm.netArgs(false), " "); got != "-netdev user,id=net0,net=10.0.2.0/24 -device e1000,netdev=net0" {
+		t.Errorf("Red por defecto inesperada: %s", got)
+	}
+}
+
+func TestNICValidation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	_, err := New(
+		WithRunner(NewFakeRunner()),
+		WithNIC(NIC{Backend: NetBridge}),
+		WithNIC(NIC{Backend: NetSocket, Listen: ":4444", Connect: "127.0.0.1:4444"}),
+		WithNIC(NIC{Backend: NetUser, Subnet: "10.0.0.0/30"}),
+		WithNIC(NIC{Backend: "vde"}),
+	)
+
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("Se esperaba ConfigError, obtenido: %v", err)
+	}
+	fields := make(map[string]bool)
+	for _, p := range cfgErr.Problems {
+		fields[p.Field] = true
+	}
+	for _, want := range []string{"NICs[0]", "NICs[1]", "NICs[2]", "NICs[3]"} {
+		if !fields[want] {
+			t.Errorf("Falta el problema del campo %s en: %v", want, err)
+		}
+	}
+
+	// Sin red de usuario no hay SSH
+	_, err = New(WithRunner(NewFakeRunner()), WithNIC(BridgeNIC("br0")))
+	if !errors.As(err, &cfgErr) || !strings.Contains(err.Error(), "SSH") {
+		t.Errorf("Se esperaba error por falta de red de usuario, obtenido: %v", err)
+	}
+}
@@ -0,0 +1,401 @@
+package goqemu
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Valores por defecto de una VM
+const (
+	DefaultRAM      = 4  // GB
+	DefaultCPU      = 2  // cores
+	DefaultDiskSize = 10 // GB
+	DefaultImageURL = "https://cloud.debian.org/images/cloud/bookworm/daily/latest/debian-12-nocloud-amd64-daily.qcow2"
+	DefaultDisplay  = DisplayGTK
+)
+
+// PortForward reenvía un puerto del host a un puerto del invitado
+type PortForward struct {
+	Protocol  string // "tcp" (default) o "udp"
+	BindAddr  string // dirección IPv4 del host en la que escucha, default 127.0.0.1
+	HostPort  int    // puerto del host, 0 para reservar uno libre al arrancar
+	GuestPort int    // puerto del invitado
+}
+
+// Option modifica la configuración de una VM creada con New
+type Option func(*QemuConfig)
+
+// WithName da un nombre fijo a la VM. Se usa como id, su directorio de
+// trabajo (claves, known_hosts) persiste y otros procesos pueden usar Attach.
+func WithName(name string) Option {
+	return func(c *QemuConfig) { c.Name = name }
+}
+
+// WithRAM define la memoria de la VM en GB
+func WithRAM(gb int) Option {
+	return func(c *QemuConfig) { c.RAM = gb }
+}
+
+// WithCPUs define el número de cores de la VM
+func WithCPUs(cores int) Option {
+	return func(c *QemuConfig) { c.CPU = cores }
+}
+
+// WithDiskSize define el tamaño del disco en GB
+func WithDiskSize(gb int) Option {
+	return func(c *QemuConfig) { c.DiskSize = gb }
+}
+
+// WithImage define la URL de la imagen qcow2 a usar
+func WithImage(url string) Option {
+	return func(c *QemuConfig) { c.ImageURL = url }
+}
+
+// WithDisplay define la interfaz gráfica: DisplayNone, DisplayGTK o DisplaySDL
+func WithDisplay(display vmDisplay) Option {
+	return func(c *QemuConfig) { c.Display = display }
+}
+
+// WithVNC expone la pantalla de la VM por VNC en el puerto indicado (5900+)
+func WithVNC(port int) Option {
+	return func(c *QemuConfig) {
+		c.Display = DisplayVNC
+		c.VNCPort = port
+	}
+}
+
+// WithPortForward reenvía un puerto TCP del host a un puerto del invitado.
+// Con hostPort 0 se reserva uno libre al arrancar, consultable con HostPort.
+func WithPortForward(hostPort, guestPort int) Option {
+	return func(c *QemuConfig) {
+		c.PortForwards = append(c.PortForwards, PortForward{Protocol: "tcp", HostPort: hostPort, GuestPort: guestPort})
+	}
+}
+
+// WithNIC añade una tarjeta de red. Sin ninguna la VM tiene una de red de
+// usuario; el SSH y los PortForwards usan la primera de red de usuario.
+func WithNIC(nic NIC) Option {
+	return func(c *QemuConfig) { c.NICs = append(c.NICs, nic) }
+}
+
+// WithSnapshotsInMemory guarda los snapshots en memoria en lugar de en disco
+func WithSnapshotsInMemory() Option {
+	return func(c *QemuConfig) { c.SnapshotsInMemory = true }
+}
+
+// WithMachine define el tipo de máquina de QEMU (pc, q35...)
+func WithMachine(machine string) Option {
+	return func(c *QemuConfig) { c.Machine = machine }
+}
+
+// WithAccel define el acelerador: AccelAuto, AccelKVM o AccelTCG
+func WithAccel(accel vmAccel) Option {
+	return func(c *QemuConfig) { c.Accel = accel }
+}
+
+// WithShutdownTimeout define la gracia del apagado ACPI en Stop
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(c *QemuConfig) { c.ShutdownTimeout = d }
+}
+
+// WithCommandTimeout define el tiempo máximo por comando en SendCommand
+func WithCommandTimeout(d time.Duration) Option {
+	return func(c *QemuConfig) { c.CommandTimeout = d }
+}
+
+// WithMaxConcurrentCommands limita los comandos de Submit ejecutados a la vez
+func WithMaxConcurrentCommands(n int) Option {
+	return func(c *QemuConfig) { c.MaxConcurrentCommands = n }
+}
+
+// WithSSHUser define el usuario SSH del invitado. Un usuario distinto de
+// root requiere una imagen con cloud-init, que lo crea en el primer arranque.
+func WithSSHUser(user string) Option {
+	return func(c *QemuConfig) { c.SSHUser = user }
+}
+
+// WithSSHKey usa una clave privada existente en lugar de generar una por VM
+func WithSSHKey(path string) Option {
+	return func(c *QemuConfig) { c.SSHKeyPath = path }
+}
+
+// WithSSHPassword define una contraseña para el usuario SSH del invitado
+func WithSSHPassword(password string) Option {
+	return func(c *QemuConfig) { c.SSHPassword = password }
+}
+
+// WithKeepalive define el intervalo entre keepalives SSH
+func WithKeepalive(interval time.Duration) Option {
+	return func(c *QemuConfig) { c.KeepaliveInterval = interval }
+}
+
+// WithReconnectTimeout define el tiempo máximo para reconectar SSH
+func WithReconnectTimeout(d time.Duration) Option {
+	return func(c *QemuConfig) { c.ReconnectTimeout = d }
+}
+
+// WithHostKeyPolicy define la verificación de la clave de host:
+// HostKeyTOFU, HostKeyStrict o HostKeyIgnore
+func WithHostKeyPolicy(policy hostKeyPolicy) Option {
+	return func(c *QemuConfig) { c.HostKeyPolicy = policy }
+}
+
+// WithReadyTimeout define el tiempo máximo de Start para que la VM esté lista
+func WithReadyTimeout(d time.Duration) Option {
+	return func(c *QemuConfig) { c.ReadyTimeout = d }
+}
+
+// WithReadyProbes añade sondas que Start espera tras el handshake SSH,
+// p. ej. WithReadyProbes(ProbeCloudInit(), ProbeTCP(80))
+func WithReadyProbes(probes ...Probe) Option {
+	return func(c *QemuConfig) { c.ReadyProbes = append(c.ReadyProbes, probes...) }
+}
+
+// WithConsoleOnly hace que Start no espere SSH, para invitados sin red o
+// sin SSH configurado que se manejan con Console
+func WithConsoleOnly() Option {
+	return func(c *QemuConfig) { c.ConsoleOnly = true }
+}
+
+// WithGuestAgent añade el canal de qemu-guest-agent, usado por GuestAgent
+func WithGuestAgent() Option {
+	return func(c *QemuConfig) { c.GuestAgent = true }
+}
+
+// WithRunner sustituye el ejecutor de procesos, p. ej. por un FakeRunner
+func WithRunner(runner Runner) Option {
+	return func(c *QemuConfig) { c.Runner = runner }
+}
+
+// DefaultConfig devuelve la configuración por defecto documentada:
+// 4GB de RAM, 2 cores, 10GB de disco, Debian 12, pantalla GTK y acelerador auto
+func DefaultConfig() QemuConfig {
+	return QemuConfig{
+		RAM:             DefaultRAM,
+		CPU:             DefaultCPU,
+		DiskSize:        DefaultDiskSize,
+		ImageURL:        DefaultImageURL,
+		Display:         DefaultDisplay,
+		Accel:           AccelAuto,
+		ShutdownTimeout: defaultShutdownTimeout,
+		CommandTimeout:  DefaultCommandTimeout,
+
+		MaxConcurrentCommands: DefaultMaxCommands,
+		SSHUser:               DefaultSSHUser,
+		HostKeyPolicy:         HostKeyTOFU,
+		KeepaliveInterval:     DefaultKeepaliveInterval,
+		ReconnectTimeout:      DefaultReconnectTimeout,
+		ReadyTimeout:          DefaultReadyTimeout,
+	}
+}
+
+// withDefaults devuelve una copia de la configuración con los campos vacíos
+// rellenados con los valores por defecto. No modifica c.
+func (c QemuConfig) withDefaults() QemuConfig {
+	d := DefaultConfig()
+
+	if c.RAM == 0 {
+		c.RAM = d.RAM
+	}
+	if c.CPU == 0 {
+		c.CPU = d.CPU
+	}
+	if c.DiskSize == 0 {
+		c.DiskSize = d.DiskSize
+	}
+	if c.ImageURL == "" {
+		c.ImageURL = d.ImageURL
+	}
+	if c.Display == "" {
+		c.Display = d.Display
+	}
+	if c.Accel == "" {
+		c.Accel = d.Accel
+	}
+	if c.ShutdownTimeout == 0 {
+		c.ShutdownTimeout = d.ShutdownTimeout
+	}
+	if c.CommandTimeout == 0 {
+		c.CommandTimeout = d.CommandTimeout
+	}
+	if c.MaxConcurrentCommands == 0 {
+		c.MaxConcurrentCommands = d.MaxConcurrentCommands
+	}
+	if c.SSHUser == "" {
+		c.SSHUser = d.SSHUser
+	}
+	if c.HostKeyPolicy == "" {
+		c.HostKeyPolicy = d.HostKeyPolicy
+	}
+	if c.KeepaliveInterval == 0 {
+		c.KeepaliveInterval = d.KeepaliveInterval
+	}
+	if c.ReconnectTimeout == 0 {
+		c.ReconnectTimeout = d.ReconnectTimeout
+	}
+	if c.ReadyTimeout == 0 {
+		c.ReadyTimeout = d.ReadyTimeout
+	}
+
+	// Copiar los slices para no compartirlos con el llamador
+	c.PortForwards = append([]PortForward(nil), c.PortForwards...)
+	c.ReadyProbes = append([]Probe(nil), c.ReadyProbes...)
+	if len(c.NICs) == 0 {
+		c.NICs = []NIC{UserNIC("")}
+	} else {
+		c.NICs = append([]NIC(nil), c.NICs...)
+	}
+	for i := range c.NICs {
+		c.NICs[i] = c.NICs[i].withDefaults()
+	}
+	for i := range c.PortForwards {
+		c.PortForwards[i] = c.PortForwards[i].withDefaults()
+	}
+
+	return c
+}
+
+// FieldError es un problema de validación de un campo de QemuConfig
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ConfigError agrupa todos los problemas encontrados al validar la configuración
+type ConfigError struct {
+	Problems []FieldError
+}
+
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return "configuración inválida: " + strings.Join(msgs, "; ")
+}
+
+// add registra un problema en el campo indicado
+func (e *ConfigError) add(field, format string, args ...any) {
+	e.Problems = append(e.Problems, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// errOrNil devuelve nil si no hay problemas
+func (e *ConfigError) errOrNil() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+// validate comprueba los valores de la configuración que no dependen del
+// binario de QEMU y registra todos los problemas en errs
+func (c *QemuConfig) validate(errs *ConfigError) {
+	if c.Name != "" && !vmNameRe.MatchString(c.Name) {
+		errs.add("Name", "nombre %q inválido, use letras, números, '.', '_' o '-'", c.Name)
+	}
+	if c.RAM < 1 {
+		errs.add("RAM", "debe ser al menos 1GB")
+	}
+	if c.CPU < 1 {
+		errs.add("CPU", "debe ser al menos 1 core")
+	}
+	if c.DiskSize < 1 {
+		errs.add("DiskSize", "debe ser al menos 1GB")
+	}
+
+	switch c.Display {
+	case DisplayNone, DisplayGTK, DisplaySDL:
+	case DisplayVNC:
+		if c.VNCPort < 5900 || c.VNCPort > 65535 {
+			errs.add("VNCPort", "se requiere un puerto VNC válido (5900-65535), obtenido %d", c.VNCPort)
+		}
+	default:
+		errs.add("Display", "valor desconocido %q, use none, gtk, sdl o vnc", c.Display)
+	}
+
+	if c.ShutdownTimeout < 0 {
+		errs.add("ShutdownTimeout", "no puede ser negativo")
+	}
+	if c.CommandTimeout < 0 {
+		errs.add("CommandTimeout", "no puede ser negativo")
+	}
+	if c.KeepaliveInterval < 0 {
+		errs.add("KeepaliveInterval", "no puede ser negativo")
+	}
+	if c.ReconnectTimeout < 0 {
+		errs.add("ReconnectTimeout", "no puede ser negativo")
+	}
+	if c.ReadyTimeout < 0 {
+		errs.add("ReadyTimeout", "no puede ser negativo")
+	}
+	for i, p := range c.ReadyProbes {
+		if p.Check == nil {
+			errs.add(fmt.Sprintf("ReadyProbes[%d]", i), "la sonda %q no tiene Check", p.Name)
+		}
+	}
+	if c.MaxConcurrentCommands < 0 {
+		errs.add("MaxConcurrentCommands", "no puede ser negativo")
+	}
+	switch c.HostKeyPolicy {
+	case HostKeyTOFU, HostKeyStrict, HostKeyIgnore:
+	default:
+		errs.add("HostKeyPolicy", "valor desconocido %q, use tofu, strict o ignore", c.HostKeyPolicy)
+	}
+	if c.SSHKeyPath != "" {
+		if _, err := os.Stat(c.SSHKeyPath); err != nil {
+			errs.add("SSHKeyPath", "no se puede leer la clave: %v", err)
+		}
+	}
+
+	for i, n := range c.NICs {
+		n.validate(fmt.Sprintf("NICs[%d]", i), errs)
+	}
+	if userNIC(c.NICs) < 0 {
+		if !c.ConsoleOnly {
+			errs.add("NICs", "SSH necesita una NIC de red de usuario, añada UserNIC o use WithConsoleOnly")
+		}
+		if len(c.PortForwards) > 0 {
+			errs.add("PortForwards", "los reenvíos de puertos necesitan una NIC de red de usuario")
+		}
+	}
+
+	c.validatePortForwards(errs)
+}
+
+// validatePortForwards añade a errs los problemas de los PortForwards
+func (c *QemuConfig) validatePortForwards(errs *ConfigError) {
+	hostPorts := make(map[string]bool)
+	for i, pf := range c.PortForwards {
+		field := fmt.Sprintf("PortForwards[%d]", i)
+		if pf.Protocol != "tcp" && pf.Protocol != "udp" {
+			errs.add(field, "protocolo %q inválido, use tcp o udp", pf.Protocol)
+		}
+		if pf.GuestPort < 1 || pf.GuestPort > 65535 {
+			errs.add(field, "puerto del invitado %d fuera de rango", pf.GuestPort)
+		}
+		if pf.HostPort < 0 || pf.HostPort > 65535 {
+			errs.add(field, "puerto del host %d fuera de rango", pf.HostPort)
+		}
+		// La regla hostfwd de slirp solo admite direcciones IPv4
+		if ip := net.ParseIP(pf.BindAddr); ip == nil {
+			errs.add(field, "dirección del host %q inválida", pf.BindAddr)
+		} else if ip.To4() == nil || strings.Contains(pf.BindAddr, ":") {
+			errs.add(field, "dirección del host %q no soportada, la red de usuario de QEMU solo reenvía direcciones IPv4", pf.BindAddr)
+		}
+		if pf.HostPort == 0 {
+			continue // se reserva al arrancar
+		}
+		key := fmt.Sprintf("%s/%d", pf.Protocol, pf.HostPort)
+		if hostPorts[key] {
+			errs.add(field, "puerto del host %s repetido", key)
+		}
+		hostPorts[key] = true
+	}
+}
@@ -0,0 +1,114 @@
+package goqemu
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewAggregatesValidationErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	_, err := New(
+		WithRunner(NewFakeRunner()),
+		WithRAM(-1),
+		WithCPUs(-2),
+		WithVNC(80),
+		WithMachine("virt"),
+		WithPortForward(8080, 80),
+		WithPortForward(8080, 8080),
+	)
+
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("Se esperaba ConfigError, obtenido: %v", err)
+	}
+
+	fields := make(map[string]bool)
+	for _, p := range cfgErr.Problems {
+		fields[p.Field] = true
+	}
+	for _, want := range []string{"RAM", "CPU", "VNCPort", "Machine", "PortForwards[1]"} {
+		if !fields[want] {
+			t.Errorf("Falta el problema del campo %s en: %v", want, err)
+		}
+	}
+}
+
+func TestNewReportsMissingQemuWithConfigErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	runner := NewFakeRunner()
+	runner.SetMissing("qemu-system-x86_64")
+	_, err := New(WithRunner(runner), WithRAM(-1))
+
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("Se esperaba ConfigError, obtenido: %v", err)
+	}
+	fields := make(map[string]bool)
+	for _, p := range cfgErr.Problems {
+		fields[p.Field] = true
+	}
+	if !fields["QEMU"] || !fields["RAM"] {
+		t.Errorf("Se esperaban los problemas de QEMU y RAM juntos: %v", err)
+	}
+}
+
+func TestNewQemuVMDoesNotMutateConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// Imagen ya descargada para no acceder a la red
+	imgPath := getImagePath(DefaultImageURL)
+	os.MkdirAll(filepath.Dir(imgPath), 0755)
+	if err := os.WriteFile(imgPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &QemuConfig{Runner: NewFakeRunner(), Accel: AccelTCG}
+	vm, err := NewQemuVM(config)
+	if err != nil {
+		t.Fatalf("Error creando VM: %v", err)
+	}
+
+	if config.Display != "" || config.ImageURL != "" || config.RAM != 0 {
+		t.Errorf("NewQemuVM modificó la configuración recibida: %+v", config)
+	}
+
+	// Los mismos valores por defecto con y sin configuración
+	if vm.config.Display != DefaultDisplay || vm.config.RAM != DefaultRAM || vm.config.ImageURL != DefaultImageURL {
+		t.Errorf("Valores por defecto incorrectos: %+v", vm.config)
+	}
+}
+
+func TestNewCreatesVMDisk(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	imgPath := getImagePath(DefaultImageURL)
+	os.MkdirAll(filepath.Dir(imgPath), 0755)
+	if err := os.WriteFile(imgPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := NewFakeRunner()
+	vm, err := New(WithRunner(runner), WithAccel(AccelTCG))
+	if err != nil {
+		t.Fatalf("Error creando VM: %v", err)
+	}
+
+	// Cada VM arranca desde su propio disco sobre la imagen compartida
+	diskPath := getDiskPath(vm.workDir)
+	calls := runner.CallsTo("qemu-img")
+	want := "qemu-img create -f qcow2 -F qcow2 -b " + imgPath + " " + diskPath + " 10G"
+	if len(calls) != 1 || calls[0].CommandLine() != want {
+		t.Fatalf("Se esperaba %q, obtenido: %v", want, calls)
+	}
+
+	args := strings.Join(vm.defaultArgs, " ")
+	if !strings.Contains(args, "-hda "+diskPath) || strings.Contains(args, imgPath) {
+		t.Errorf("La VM debería arrancar desde %s: %s", diskPath, args)
+	}
+}
@@ -0,0 +1,243 @@
+package goqemu
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const defaultBindAddr = "127.0.0.1" // Dirección del host de los reenvíos de puertos
+
+// withDefaults rellena el protocolo y la dirección del host vacíos
+func (pf PortForward) withDefaults() PortForward {
+	if pf.Protocol == "" {
+		pf.Protocol = "tcp"
+	}
+	if pf.BindAddr == "" {
+		pf.BindAddr = defaultBindAddr
+	}
+	return pf
+}
+
+// hostfwd devuelve la regla de slirp del reenvío, p. ej. tcp:127.0.0.1:8080-:80
+func (pf PortForward) hostfwd() string {
+	return fmt.Sprintf("%s:%s:%d-:%d", pf.Protocol, pf.BindAddr, pf.HostPort, pf.GuestPort)
+}
+
+// PortForwards devuelve los reenvíos de puertos de la VM. En ejecución
+// incluyen los puertos reservados automáticamente y los añadidos con
+// AddPortForward.
+func (vm *QemuVM) PortForwards() []PortForward {
+	vm.portsMu.Lock()
+	defer vm.portsMu.Unlock()
+	if vm.ports != nil {
+		return append([]PortForward(nil), vm.ports...)
+	}
+	if vm.config == nil {
+		return nil
+	}
+	return append([]PortForward(nil), vm.config.PortForwards...)
+}
+
+// HostPort devuelve el puerto del host reenviado al puerto guestPort del
+// invitado, preferentemente por TCP, o 0 si no hay ninguno. HostPort(22)
+// devuelve el puerto SSH.
+func (vm *QemuVM) HostPort(guestPort int) int {
+	udp := 0
+	for _, pf := range vm.PortForwards() {
+		if pf.GuestPort != guestPort {
+			continue
+		}
+		if pf.Protocol == "tcp" {
+			return pf.HostPort
+		}
+		if udp == 0 {
+			udp = pf.HostPort
+		}
+	}
+	if udp == 0 && guestPort == 22 {
+		return vm.sshPort
+	}
+	return udp
+}
+
+// reservePort reserva el puerto del host del reenvío: uno libre si HostPort
+// es 0. allocated indica si hay que liberarlo con releasePort.
+func reservePort(pf PortForward) (_ PortForward, allocated bool, err error) {
+	if pf.HostPort != 0 {
+		if pf.Protocol == "tcp" && !isPortAvailable(pf.HostPort) {
+			return pf, false, fmt.Errorf("el puerto %d del host ya está en uso", pf.HostPort)
+		}
+		return pf, false, nil
+	}
+
+	pf.HostPort, err = allocateProtoPort(pf.Protocol)
+	if err != nil {
+		return pf, false, fmt.Errorf("error reservando puerto para %s/%d: %v", pf.Protocol, pf.GuestPort, err)
+	}
+	return pf, true, nil
+}
+
+// allocatePortForwards reserva los puertos de los PortForwards de la
+// configuración antes de lanzar QEMU
+func (vm *QemuVM) allocatePortForwards() error {
+	vm.portsMu.Lock()
+	defer vm.portsMu.Unlock()
+	if vm.ports != nil {
+		return nil
+	}
+
+	ports := make([]PortForward, 0, len(vm.config.PortForwards))
+	for _, pf := range vm.config.PortForwards {
+		pf, allocated, err := reservePort(pf.withDefaults())
+		if err != nil {
+			vm.releasePortsLocked()
+			return err
+		}
+		if allocated {
+			vm.allocatedPorts = append(vm.allocatedPorts, pf.HostPort)
+		}
+		ports = append(ports, pf)
+	}
+	vm.ports = ports
+	return nil
+}
+
+// releasePortForwards libera los puertos reservados al detener la VM
+func (vm *QemuVM) releasePortForwards() {
+	vm.portsMu.Lock()
+	defer vm.portsMu.Unlock()
+	vm.releasePortsLocked()
+}
+
+func (vm *QemuVM) releasePortsLocked() {
+	for _, port := range vm.allocatedPorts {
+		releasePort(port)
+	}
+	vm.allocatedPorts = nil
+	vm.ports = nil
+}
+
+// hostfwdNetdev devuelve el id del netdev de red de usuario que recibe los reenvíos
+func (vm *QemuVM) hostfwdNetdev() (string, error) {
+	i := userNIC(vm.nics())
+	if i < 0 {
+		return "", errors.New("la VM no tiene una NIC de red de usuario")
+	}
+	return fmt.Sprintf("net%d", i), nil
+}
+
+// AddPortForward añade un reenvío de puertos a la VM en ejecución con
+// hostfwd_add del monitor. Devuelve el reenvío con el puerto del host
+// reservado si HostPort es 0. Se elimina al detener la VM.
+func (vm *QemuVM) AddPortForward(pf PortForward) (PortForward, error) {
+	if err := vm.requireState("AddPortForward", StateRunning, StatePaused); err != nil {
+		return pf, err
+	}
+
+	pf = pf.withDefaults()
+	errs := &ConfigError{}
+	(&QemuConfig{PortForwards: []PortForward{pf}}).validatePortForwards(errs)
+	if err := errs.errOrNil(); err != nil {
+		return pf, err
+	}
+
+	netdev, err := vm.hostfwdNetdev()
+	if err != nil {
+		return pf, err
+	}
+
+	pf, allocated, err := reservePort(pf)
+	if err != nil {
+		return pf, err
+	}
+
+	err = vm.monitorCommand("AddPortForward", func(c *QMPClient) error {
+		// HMP no devuelve nada si la regla se añadió
+		out, err := c.HumanMonitorCommand("hostfwd_add " + netdev + " " + pf.hostfwd())
+		if err == nil && strings.TrimSpace(out) != "" {
+			err = errors.New(strings.TrimSpace(out))
+		}
+		return err
+	})
+	if err != nil {
+		if allocated {
+			releasePort(pf.HostPort)
+		}
+		return pf, err
+	}
+
+	vm.portsMu.Lock()
+	if vm.ports == nil {
+		vm.ports = []PortForward{}
+	}
+	vm.ports = append(vm.ports, pf)
+	if allocated {
+		vm.allocatedPorts = append(vm.allocatedPorts, pf.HostPort)
+	}
+	vm.portsMu.Unlock()
+
+	vm.updateSession()
+	return pf, nil
+}
+
+// RemovePortForward elimina de la VM en ejecución el reenvío con el
+// protocolo, la dirección y el puerto del host de pf
+func (vm *QemuVM) RemovePortForward(pf PortForward) error {
+	if err := vm.requireState("RemovePortForward", StateRunning, StatePaused); err != nil {
+		return err
+	}
+	pf = pf.withDefaults()
+
+	vm.portsMu.Lock()
+	index := vm.findPortLocked(pf)
+	vm.portsMu.Unlock()
+	if index < 0 {
+		return fmt.Errorf("no hay un reenvío del puerto %s:%s:%d", pf.Protocol, pf.BindAddr, pf.HostPort)
+	}
+
+	netdev, err := vm.hostfwdNetdev()
+	if err != nil {
+		return err
+	}
+
+	rule := fmt.Sprintf("%s:%s:%d", pf.Protocol, pf.BindAddr, pf.HostPort)
+	err = vm.monitorCommand("RemovePortForward", func(c *QMPClient) error {
+		// HMP responde "host forwarding rule for ... removed" o "... not found"
+		out, err := c.HumanMonitorCommand("hostfwd_remove " + netdev + " " + rule)
+		if err == nil && !strings.Contains(out, "removed") {
+			err = errors.New(strings.TrimSpace(out))
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	vm.portsMu.Lock()
+	if i := vm.findPortLocked(pf); i >= 0 {
+		vm.ports = append(vm.ports[:i], vm.ports[i+1:]...)
+	}
+	for i, port := range vm.allocatedPorts {
+		if port == pf.HostPort {
+			releasePort(port)
+			vm.allocatedPorts = append(vm.allocatedPorts[:i], vm.allocatedPorts[i+1:]...)
+			break
+		}
+	}
+	vm.portsMu.Unlock()
+
+	vm.updateSession()
+	return nil
+}
+
+// findPortLocked devuelve el índice del reenvío activo con el protocolo,
+// la dirección y el puerto del host de pf, o -1
+func (vm *QemuVM) findPortLocked(pf PortForward) int {
+	for i, p := range vm.ports {
+		if p.Protocol == pf.Protocol && p.BindAddr == pf.BindAddr && p.HostPort == pf.HostPort {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,170 @@
+package goqemu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPortForwardsAllocation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	config := QemuConfig{
+		PortForwards: []PortForward{
+			{GuestPort: 80},
+			{Protocol: "udp", BindAddr: "0.0.0.0", GuestPort: 53},
+		},
+	}.withDefaults()
+	vm := &QemuVM{config: &config, sshPort: 2222}
+
+	if err := vm.allocatePortForwards(); err != nil {
+		t.Fatalf("Error reservando puertos: %v", err)
+	}
+	http, dns := vm.HostPort(80), vm.HostPort(53)
+	if http == 0 || dns == 0 || http == dns {
+		t.Fatalf("Puertos reservados inesperados: %v", vm.PortForwards())
+	}
+	if vm.HostPort(22) != 2222 {
+		t.Errorf("HostPort(22) debería devolver el puerto SSH, obtenido %d", vm.HostPort(22))
+	}
+	if vm.HostPort(443) != 0 {
+		t.Errorf("HostPort(443) debería ser 0, obtenido %d", vm.HostPort(443))
+	}
+
+	// Otra VM no puede reservar los mismos puertos
+	if lockPort(getPortsDir(), http) {
+		releasePort(http)
+		t.Errorf("El puerto %d debería estar bloqueado", http)
+	}
+
+	cmdline := strings.Join(vm.netArgs(true), " ")
+	for _, want := range []string{
+		fmt.Sprintf("hostfwd=tcp:127.0.0.1:%d-:80", http),
+		fmt.Sprintf("hostfwd=udp:0.0.0.0:%d-:53", dns),
+	} {
+		if !strings.Contains(cmdline, want) {
+			t.Errorf("Falta %q en: %s", want, cmdline)
+		}
+	}
+
+	vm.releasePortForwards()
+	if vm.HostPort(80) != 0 {
+		t.Errorf("Tras liberar se esperaba el puerto de la configuración, obtenido %d", vm.HostPort(80))
+	}
+	if !lockPort(getPortsDir(), http) {
+		t.Errorf("El puerto %d debería estar liberado", http)
+	}
+	releasePort(http)
+}
+
+func TestPortForwardValidation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	_, err := New(
+		WithRunner(NewFakeRunner()),
+		WithPortForward(0, 80),
+		WithPortForward(0, 443), // varios puertos automáticos no se consideran duplicados
+		func(c *QemuConfig) {
+			c.PortForwards = append(c.PortForwards, PortForward{BindAddr: "localhost", GuestPort: 8080})
+		},
+	)
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) || len(cfgErr.Problems) != 1 || !strings.Contains(err.Error(), "localhost") {
+		t.Errorf("Se esperaba un único error por BindAddr, obtenido: %v", err)
+	}
+
+	// hostfwd no admite direcciones IPv6
+	for _, addr := range []string{"::1", "::ffff:127.0.0.1"} {
+		_, err = New(WithRunner(NewFakeRunner()), func(c *QemuConfig) {
+			c.PortForwards = append(c.PortForwards, PortForward{BindAddr: addr, GuestPort: 80})
+		})
+		if !errors.As(err, &cfgErr) || !strings.Contains(err.Error(), "IPv4") {
+			t.Errorf("Se esperaba error por la dirección IPv6 %s, obtenido: %v", addr, err)
+		}
+	}
+}
+
+func TestAddRemovePortForward(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var received []string
+	socketPath, _ := fakeQMPServer(t, func(cmd string, args json.RawMessage) any {
+		var hmp struct {
+			CommandLine string `json:"command-line"`
+		}
+		json.Unmarshal(args, &hmp)
+		received = append(received, hmp.CommandLine)
+
+		switch {
+		case strings.HasPrefix(hmp.CommandLine, "hostfwd_remove"):
+			return "host forwarding rule for " + strings.Fields(hmp.CommandLine)[2] + " removed\r\n"
+		case strings.HasSuffix(hmp.CommandLine, "-:81"):
+			return "Could not set up host forwarding rule '" + strings.Fields(hmp.CommandLine)[2] + "'\r\n"
+		}
+		return ""
+	})
+
+	qmp, err := DialQMP(socketPath, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Error conectando QMP: %v", err)
+	}
+	defer qmp.Close()
+
+	config := QemuConfig{NICs: []NIC{BridgeNIC("br0"), UserNIC("")}}.withDefaults()
+	vm := &QemuVM{config: &config, state: StateRunning, qmp: qmp, workDir: t.TempDir()}
+	t.Cleanup(vm.releasePortForwards)
+
+	pf, err := vm.AddPortForward(PortForward{GuestPort: 80})
+	if err != nil {
+		t.Fatalf("Error añadiendo reenvío: %v", err)
+	}
+	if pf.HostPort == 0 || vm.HostPort(80) != pf.HostPort {
+		t.Fatalf("Puerto reservado inesperado: %+v, HostPort(80) = %d", pf, vm.HostPort(80))
+	}
+	wantAdd := fmt.Sprintf("hostfwd_add net1 tcp:127.0.0.1:%d-:80", pf.HostPort)
+	if received[0] != wantAdd {
+		t.Errorf("Comando esperado %q, obtenido %q", wantAdd, received[0])
+	}
+
+	// El puerto reservado queda registrado en la sesión para Attach
+	var info sessionInfo
+	data, err := os.ReadFile(filepath.Join(vm.workDir, "session.json"))
+	if err == nil {
+		err = json.Unmarshal(data, &info)
+	}
+	if err != nil || len(info.Ports) != 1 || info.Ports[0].HostPort != pf.HostPort {
+		t.Errorf("Sesión sin el reenvío: %+v, %v", info, err)
+	}
+
+	// QEMU rechaza la regla: el error del monitor se devuelve
+	if _, err := vm.AddPortForward(PortForward{GuestPort: 81}); err == nil || !strings.Contains(err.Error(), "Could not set up") {
+		t.Errorf("Se esperaba el error del monitor, obtenido: %v", err)
+	}
+	if len(vm.PortForwards()) != 1 {
+		t.Errorf("El reenvío rechazado no debería registrarse: %v", vm.PortForwards())
+	}
+
+	if err := vm.RemovePortForward(PortForward{HostPort: 9999}); err == nil {
+		t.Error("Eliminar un reenvío inexistente debería fallar")
+	}
+	if err := vm.RemovePortForward(pf); err != nil {
+		t.Fatalf("Error eliminando reenvío: %v", err)
+	}
+	if vm.HostPort(80) != 0 || len(vm.PortForwards()) != 0 {
+		t.Errorf("El reenvío sigue registrado: %v", vm.PortForwards())
+	}
+	if !lockPort(getPortsDir(), pf.HostPort) {
+		t.Errorf("El puerto %d debería estar liberado", pf.HostPort)
+	}
+	releasePort(pf.HostPort)
+
+	vm.state = StateStopped
+	if _, err := vm.AddPortForward(PortForward{GuestPort: 80}); err == nil {
+		t.Error("AddPortForward debería fallar con la VM detenida")
+	}
+}
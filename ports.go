@@ -0,0 +1,106 @@
+package goqemu
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const portAllocAttempts = 50 // Intentos máximos para reservar un puerto libre
+
+// getPortsDir devuelve el directorio donde se registran los puertos reservados
+func getPortsDir() string {
+	return filepath.Join(os.Getenv("HOME"), "qemu", "ports")
+}
+
+// allocatePort reserva un puerto TCP libre del host. La reserva se registra
+// en ~/qemu/ports/<puerto>.lock con el pid del proceso para que otros procesos
+// goqemu (p. ej. paquetes de go test en paralelo) no elijan el mismo puerto.
+func allocatePort() (int, error) {
+	return allocateProtoPort("tcp")
+}
+
+// allocateProtoPort reserva un puerto libre del host para el protocolo
+// proto, "tcp" o "udp"
+func allocateProtoPort(proto string) (int, error) {
+	dir := getPortsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("error creando directorio de puertos: %v", err)
+	}
+
+	for i := 0; i < portAllocAttempts; i++ {
+		// Pedir al sistema un puerto libre
+		port, err := freePort(proto)
+		if err != nil {
+			return 0, fmt.Errorf("error buscando puerto libre: %v", err)
+		}
+
+		if lockPort(dir, port) {
+			return port, nil
+		}
+	}
+
+	return 0, errors.New("no se pudo reservar un puerto libre")
+}
+
+// freePort devuelve un puerto que el sistema considera libre para proto
+func freePort(proto string) (int, error) {
+	if proto == "udp" {
+		conn, err := net.ListenPacket("udp", ":0")
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).Port, nil
+	}
+
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// lockPort crea el archivo de bloqueo del puerto de forma exclusiva.
+// Los bloqueos de procesos que ya no existen se eliminan.
+func lockPort(dir string, port int) bool {
+	path := filepath.Join(dir, fmt.Sprintf("%d.lock", port))
+
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return true
+		}
+		if !os.IsExist(err) {
+			return false
+		}
+
+		// Reclamar el bloqueo si el proceso que lo creó ya terminó
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return false
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil || pid == os.Getpid() || processAlive(pid) {
+			return false
+		}
+		os.Remove(path)
+	}
+
+	return false
+}
+
+// releasePort libera la reserva de un puerto
+func releasePort(port int) {
+	if port == 0 {
+		return
+	}
+	os.Remove(filepath.Join(getPortsDir(), fmt.Sprintf("%d.lock", port)))
+}
@@ -0,0 +1,55 @@
+package goqemu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAllocatePort(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	ports := make(map[int]bool)
+	for i := 0; i < 5; i++ {
+		port, err := allocatePort()
+		if err != nil {
+			t.Fatalf("Error reservando puerto: %v", err)
+		}
+		if ports[port] {
+			t.Fatalf("Puerto %d reservado dos veces", port)
+		}
+		ports[port] = true
+
+		lock := filepath.Join(getPortsDir(), fmt.Sprintf("%d.lock", port))
+		if _, err := os.Stat(lock); err != nil {
+			t.Errorf("No se creó el bloqueo del puerto %d: %v", port, err)
+		}
+	}
+
+	for port := range ports {
+		// Un puerto reservado por este proceso no se puede volver a bloquear
+		if lockPort(getPortsDir(), port) {
+			t.Errorf("El puerto %d se bloqueó dos veces", port)
+		}
+		releasePort(port)
+		if !lockPort(getPortsDir(), port) {
+			t.Errorf("El puerto %d liberado no se pudo volver a bloquear", port)
+		}
+		releasePort(port)
+	}
+}
+
+func TestLockPortStale(t *testing.T) {
+	dir := t.TempDir()
+
+	// Bloqueo de un proceso que ya no existe
+	err := os.WriteFile(filepath.Join(dir, "40000.lock"), []byte("999999999\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !lockPort(dir, 40000) {
+		t.Error("No se reclamó el bloqueo de un proceso terminado")
+	}
+}
@@ -0,0 +1,79 @@
+package goqemu
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrVMPaused se devuelve al intentar usar SSH mientras la VM está pausada
+var ErrVMPaused = errors.New("la VM está pausada, use Resume antes de enviar comandos")
+
+// Pause congela las CPUs del invitado mediante el monitor QMP (stop)
+func (vm *QemuVM) Pause() error {
+	if _, err := vm.transition("Pause", StatePaused); err != nil {
+		return err
+	}
+
+	if err := vm.monitorCommand("Pause", (*QMPClient).Stop); err != nil {
+		vm.setState(StateRunning)
+		return err
+	}
+
+	return nil
+}
+
+// Resume reanuda una VM pausada mediante el monitor QMP (cont)
+func (vm *QemuVM) Resume() error {
+	if _, err := vm.transition("Resume", StateRunning); err != nil {
+		return err
+	}
+
+	if err := vm.monitorCommand("Resume", (*QMPClient).Cont); err != nil {
+		vm.setState(StatePaused)
+		return err
+	}
+
+	return nil
+}
+
+// Reset reinicia la VM en caliente simulando un corte de energía (system_reset).
+// Una VM pausada sigue pausada después del reinicio.
+func (vm *QemuVM) Reset() error {
+	if err := vm.requireState("Reset", StateRunning, StatePaused); err != nil {
+		return err
+	}
+
+	if err := vm.monitorCommand("Reset", (*QMPClient).SystemReset); err != nil {
+		return err
+	}
+
+	// La conexión SSH no sobrevive al reinicio del invitado
+	vm.closeSSH()
+
+	return nil
+}
+
+// monitorCommand ejecuta un comando QMP sin argumentos sobre la VM
+func (vm *QemuVM) monitorCommand(op string, command func(*QMPClient) error) error {
+	qmp := vm.QMP()
+	if qmp == nil {
+		return fmt.Errorf("%s: monitor QMP no disponible", op)
+	}
+	if err := command(qmp); err != nil {
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	return nil
+}
+
+// checkSSHAvailable falla inmediatamente si la VM no puede atender SSH,
+// en lugar de esperar el timeout de la conexión
+func (vm *QemuVM) checkSSHAvailable(op string) error {
+	switch vm.Status() {
+	case StatePaused:
+		return ErrVMPaused
+	case StateRunning, StateStarting:
+		return nil
+	default:
+		return &StateError{Op: op, State: vm.Status()}
+	}
+}
@@ -0,0 +1,94 @@
+package goqemu
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPauseResume(t *testing.T) {
+	var received []string
+	socketPath, _ := fakeQMPServer(t, func(cmd string, args json.RawMessage) any {
+		received = append(received, cmd)
+		return map[string]any{}
+	})
+
+	qmp, err := DialQMP(socketPath, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Error conectando QMP: %v", err)
+	}
+	defer qmp.Close()
+
+	vm := &QemuVM{config: &QemuConfig{}, state: StateRunning, qmp: qmp}
+
+	if err := vm.Pause(); err != nil {
+		t.Fatalf("Error pausando VM: %v", err)
+	}
+	if vm.Status() != StatePaused {
+		t.Errorf("Estado esperado %s, obtenido %s", StatePaused, vm.Status())
+	}
+
+	// Los comandos SSH fallan de inmediato mientras la VM está pausada
+	if cmd := vm.SendCommand("true"); !errors.Is(cmd.Err, ErrVMPaused) {
+		t.Errorf("Se esperaba ErrVMPaused, obtenido: %v", cmd.Err)
+	}
+
+	if err := vm.Pause(); err == nil {
+		t.Error("Pausar una VM ya pausada debería fallar")
+	}
+
+	if err := vm.Reset(); err != nil {
+		t.Fatalf("Error reiniciando VM: %v", err)
+	}
+
+	if err := vm.Resume(); err != nil {
+		t.Fatalf("Error reanudando VM: %v", err)
+	}
+	if vm.Status() != StateRunning {
+		t.Errorf("Estado esperado %s, obtenido %s", StateRunning, vm.Status())
+	}
+
+	want := []string{"stop", "system_reset", "cont"}
+	if len(received) != len(want) {
+		t.Fatalf("Comandos QMP esperados %v, obtenidos %v", want, received)
+	}
+	for i := range want {
+		if received[i] != want[i] {
+			t.Errorf("Comandos QMP esperados %v, obtenidos %v", want, received)
+			break
+		}
+	}
+}
+
+func TestMonitorCommandDuringRelease(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	socketPath, _ := fakeQMPServer(t, func(cmd string, args json.RawMessage) any {
+		return map[string]any{}
+	})
+	qmp, err := DialQMP(socketPath, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Error conectando QMP: %v", err)
+	}
+
+	vm := &QemuVM{config: &QemuConfig{}, state: StateRunning, qmp: qmp, workDir: t.TempDir()}
+
+	// Liberar los recursos mientras se envía un comando no debe
+	// producir una carrera sobre el cliente QMP
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for vm.monitorCommand("reset", (*QMPClient).SystemReset) == nil {
+		}
+	}()
+	vm.releaseResources()
+	<-done
+
+	if vm.QMP() != nil {
+		t.Error("El cliente QMP debería liberarse")
+	}
+	if err := vm.monitorCommand("reset", (*QMPClient).SystemReset); err == nil {
+		t.Error("Sin monitor QMP el comando debería fallar")
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package goqemu
+
+import (
+	"os"
+	"syscall"
+)
+
+// processAlive verifica si existe un proceso con el pid indicado
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// terminateProcess solicita al proceso que termine (SIGTERM)
+func terminateProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package goqemu
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// processAlive verifica si existe un proceso con el pid indicado
+func processAlive(pid int) bool {
+	output, err := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid)).Output()
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(output), fmt.Sprintf(" %d ", pid))
+}
+
+// terminateProcess solicita al proceso que termine. Windows no tiene SIGTERM,
+// taskkill sin /F envía WM_CLOSE a la ventana de QEMU.
+func terminateProcess(p *os.Process) error {
+	return exec.Command("taskkill", "/PID", fmt.Sprintf("%d", p.Pid)).Run()
+}
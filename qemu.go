@@ -3,71 +3,43 @@ package goqemu
 import (
 	"errors"
 	"fmt"
-	"os/exec"
-	"regexp"
-	"strings"
 )
 
 const minQemuVersion = "8.1.0" // Versión mínima requerida de QEMU
 
-// checkQemuInstalled verifica si QEMU está instalado en el sistema
-func checkQemuInstalled() error {
-	_, err := exec.LookPath("qemu-system-x86_64")
+// checkQemuInstalled verifica si QEMU está instalado en el sistema con la
+// versión mínima requerida y devuelve sus capacidades
+func checkQemuInstalled(runner Runner) (*Capabilities, error) {
+	_, err := runner.LookPath("qemu-system-x86_64")
 	if err != nil {
-		return errors.New("QEMU no está instalado. Por favor instale QEMU antes de continuar")
+		return nil, errors.New("QEMU no está instalado. Por favor instale QEMU antes de continuar")
 	}
 
-	if err := checkQemuVersion(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// checkQemuVersion verifica si la versión instalada cumple con los requisitos
-func checkQemuVersion() error {
-	version, err := getQemuVersion()
+	caps, err := ProbeCapabilities(runner, "qemu-system-x86_64")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if compareVersions(version, minQemuVersion) < 0 {
-		return fmt.Errorf("versión de QEMU (%s) es menor que la requerida (%s)", version, minQemuVersion)
+	if err := checkQemuVersion(caps.Version); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return caps, nil
 }
 
-// getQemuVersion obtiene la versión instalada de QEMU
-func getQemuVersion() (string, error) {
-	out, err := exec.Command("qemu-system-x86_64", "--version").Output()
-	if err != nil {
-		return "", fmt.Errorf("error obteniendo versión de QEMU: %v", err)
-	}
-
-	// Extraer versión usando regex
-	re := regexp.MustCompile(`QEMU emulator version (\d+\.\d+\.\d+)`)
-	matches := re.FindStringSubmatch(string(out))
-	if len(matches) < 2 {
-		return "", errors.New("no se pudo determinar la versión de QEMU")
+// checkQemuVersion verifica si la versión instalada cumple con los requisitos
+func checkQemuVersion(version Version) error {
+	if compareVersions(version.String(), minQemuVersion) < 0 {
+		return fmt.Errorf("versión de QEMU (%s) es menor que la requerida (%s)", version, minQemuVersion)
 	}
 
-	return matches[1], nil
+	return nil
 }
 
-// compareVersions compara dos versiones en formato semver
+// compareVersions compara dos versiones en formato semver de forma numérica,
+// de modo que "10.0.0" es mayor que "8.1.0"
 func compareVersions(v1, v2 string) int {
-	v1Parts := strings.Split(v1, ".")
-	v2Parts := strings.Split(v2, ".")
-
-	for i := 0; i < len(v1Parts) && i < len(v2Parts); i++ {
-		if v1Parts[i] < v2Parts[i] {
-			return -1
-		}
-		if v1Parts[i] > v2Parts[i] {
-			return 1
-		}
-	}
-
-	return 0
+	a, _ := ParseVersion(v1)
+	b, _ := ParseVersion(v2)
+	return a.Compare(b)
 }
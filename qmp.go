@@ -0,0 +1,363 @@
+package goqemu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const qmpTimeout = 10 * time.Second // Tiempo máximo de espera por respuesta QMP
+
+// QMPClient es un cliente del protocolo QMP (QEMU Machine Protocol)
+// conectado al socket unix de una VM en ejecución
+type QMPClient struct {
+	conn     net.Conn
+	Greeting QMPGreeting
+
+	writeMu sync.Mutex // serializa escrituras en el socket
+
+	mu      sync.Mutex
+	pending map[string]chan qmpResponse
+	nextID  uint64
+	err     error // error que cerró la conexión
+	onEvent func(QMPEvent)
+
+	events chan QMPEvent
+	done   chan struct{}
+}
+
+// QMPGreeting es el saludo inicial que envía QEMU al conectarse
+type QMPGreeting struct {
+	QMP struct {
+		Version struct {
+			Qemu struct {
+				Major int `json:"major"`
+				Minor int `json:"minor"`
+				Micro int `json:"micro"`
+			} `json:"qemu"`
+			Package string `json:"package"`
+		} `json:"version"`
+		Capabilities []string `json:"capabilities"`
+	} `json:"QMP"`
+}
+
+// QMPError es un error devuelto por QEMU como respuesta a un comando
+type QMPError struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+func (e *QMPError) Error() string {
+	return fmt.Sprintf("QMP %s: %s", e.Class, e.Desc)
+}
+
+// QMPEvent es un evento asíncrono emitido por QEMU (SHUTDOWN, STOP, RESUME...)
+type QMPEvent struct {
+	Name      string
+	Data      map[string]any
+	Timestamp time.Time
+}
+
+// QMPStatus es la respuesta de query-status
+type QMPStatus struct {
+	Running bool   `json:"running"`
+	Status  string `json:"status"` // running, paused, shutdown, internal-error...
+}
+
+// QMPBlockDevice es un dispositivo de bloque devuelto por query-block
+type QMPBlockDevice struct {
+	Device    string            `json:"device"`
+	QDev      string            `json:"qdev"`
+	Removable bool              `json:"removable"`
+	Locked    bool              `json:"locked"`
+	TrayOpen  bool              `json:"tray_open"`
+	Inserted  *QMPBlockInserted `json:"inserted"`
+}
+
+// QMPBlockInserted describe el medio insertado en un dispositivo de bloque
+type QMPBlockInserted struct {
+	File     string `json:"file"`
+	Driver   string `json:"drv"`
+	ReadOnly bool   `json:"ro"`
+}
+
+type qmpRequest struct {
+	Execute   string `json:"execute"`
+	Arguments any    `json:"arguments,omitempty"`
+	ID        string `json:"id"`
+}
+
+type qmpResponse struct {
+	Return json.RawMessage `json:"return"`
+	Error  *QMPError       `json:"error"`
+	ID     string          `json:"id"`
+
+	Event     string         `json:"event"`
+	Data      map[string]any `json:"data"`
+	Timestamp struct {
+		Seconds      int64 `json:"seconds"`
+		Microseconds int64 `json:"microseconds"`
+	} `json:"timestamp"`
+}
+
+// DialQMP conecta con el socket QMP de QEMU y negocia las capacidades.
+// Reintenta hasta timeout mientras QEMU crea el socket.
+func DialQMP(socketPath string, timeout time.Duration) (*QMPClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return DialQMPContext(ctx, socketPath)
+}
+
+// DialQMPContext es como DialQMP pero reintenta hasta que se cancele ctx
+func DialQMPContext(ctx context.Context, socketPath string) (*QMPClient, error) {
+	var conn net.Conn
+	var err error
+
+	for {
+		conn, err = net.DialTimeout("unix", socketPath, time.Second)
+		if err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error conectando a QMP en %s: %v", socketPath, err)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	c := &QMPClient{
+		conn:    conn,
+		pending: make(map[string]chan qmpResponse),
+		events:  make(chan QMPEvent, 64),
+		done:    make(chan struct{}),
+	}
+
+	dec := json.NewDecoder(conn)
+
+	// Leer saludo inicial
+	conn.SetReadDeadline(time.Now().Add(qmpTimeout))
+	if err := dec.Decode(&c.Greeting); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error leyendo saludo QMP: %v", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	go c.readLoop(dec)
+
+	// Salir del modo de negociación de capacidades
+	if err := c.Execute("qmp_capabilities", nil, nil); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("error negociando capacidades QMP: %v", err)
+	}
+
+	return c, nil
+}
+
+// readLoop despacha respuestas a los comandos pendientes y eventos al canal de eventos
+func (c *QMPClient) readLoop(dec *json.Decoder) {
+	var err error
+
+	for {
+		var resp qmpResponse
+		if err = dec.Decode(&resp); err != nil {
+			break
+		}
+
+		if resp.Event != "" {
+			event := QMPEvent{
+				Name:      resp.Event,
+				Data:      resp.Data,
+				Timestamp: time.Unix(resp.Timestamp.Seconds, resp.Timestamp.Microseconds*1000),
+			}
+
+			c.mu.Lock()
+			onEvent := c.onEvent
+			c.mu.Unlock()
+			if onEvent != nil {
+				onEvent(event)
+			}
+
+			// No bloquear las respuestas si nadie consume los eventos
+			select {
+			case c.events <- event:
+			default:
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[resp.ID]
+		delete(c.pending, resp.ID)
+		c.mu.Unlock()
+
+		if ok {
+			ch <- resp
+		}
+	}
+
+	c.mu.Lock()
+	c.err = fmt.Errorf("conexión QMP cerrada: %v", err)
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+
+	close(c.events)
+	close(c.done)
+}
+
+// Execute ejecuta un comando QMP de forma síncrona. Si result no es nil
+// se decodifica en él el campo "return" de la respuesta.
+func (c *QMPClient) Execute(command string, args any, result any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := strconv.FormatUint(c.nextID, 10)
+	ch := make(chan qmpResponse, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	data, err := json.Marshal(qmpRequest{Execute: command, Arguments: args, ID: id})
+	if err != nil {
+		c.forget(id)
+		return fmt.Errorf("error codificando comando QMP %s: %v", command, err)
+	}
+
+	c.writeMu.Lock()
+	c.conn.SetWriteDeadline(time.Now().Add(qmpTimeout))
+	_, err = c.conn.Write(append(data, '\n'))
+	c.writeMu.Unlock()
+	if err != nil {
+		c.forget(id)
+		return fmt.Errorf("error enviando comando QMP %s: %v", command, err)
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return c.closeErr()
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil && len(resp.Return) > 0 {
+			if err := json.Unmarshal(resp.Return, result); err != nil {
+				return fmt.Errorf("error decodificando respuesta QMP %s: %v", command, err)
+			}
+		}
+		return nil
+	case <-time.After(qmpTimeout):
+		c.forget(id)
+		return fmt.Errorf("timeout esperando respuesta QMP a %s", command)
+	}
+}
+
+func (c *QMPClient) forget(id string) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *QMPClient) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return errors.New("conexión QMP cerrada")
+}
+
+// Events devuelve el canal de eventos asíncronos. Se cierra al cerrar la conexión.
+// Los eventos se descartan si el canal está lleno.
+func (c *QMPClient) Events() <-chan QMPEvent {
+	return c.events
+}
+
+// setEventHandler registra una función que recibe todos los eventos,
+// independientemente de quién consuma el canal de Events
+func (c *QMPClient) setEventHandler(fn func(QMPEvent)) {
+	c.mu.Lock()
+	c.onEvent = fn
+	c.mu.Unlock()
+}
+
+// Done se cierra cuando la conexión QMP termina
+func (c *QMPClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close cierra la conexión QMP
+func (c *QMPClient) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+// QueryStatus devuelve el estado de ejecución de la VM
+func (c *QMPClient) QueryStatus() (*QMPStatus, error) {
+	var status QMPStatus
+	if err := c.Execute("query-status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// QueryBlock devuelve los dispositivos de bloque de la VM
+func (c *QMPClient) QueryBlock() ([]QMPBlockDevice, error) {
+	var devices []QMPBlockDevice
+	if err := c.Execute("query-block", nil, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// SystemPowerdown solicita un apagado ACPI al sistema invitado
+func (c *QMPClient) SystemPowerdown() error {
+	return c.Execute("system_powerdown", nil, nil)
+}
+
+// Stop pausa la ejecución de todas las CPUs virtuales
+func (c *QMPClient) Stop() error {
+	return c.Execute("stop", nil, nil)
+}
+
+// Cont reanuda la ejecución de la VM pausada
+func (c *QMPClient) Cont() error {
+	return c.Execute("cont", nil, nil)
+}
+
+// Quit termina QEMU inmediatamente. QEMU puede cerrar el socket antes
+// de que llegue la respuesta, en ese caso no se considera error.
+func (c *QMPClient) Quit() error {
+	err := c.Execute("quit", nil, nil)
+	if err != nil {
+		select {
+		case <-c.done:
+			return nil
+		case <-time.After(time.Second):
+		}
+	}
+	return err
+}
+
+// SystemReset reinicia la VM como un corte de energía, sin apagado del invitado
+func (c *QMPClient) SystemReset() error {
+	return c.Execute("system_reset", nil, nil)
+}
+
+// HumanMonitorCommand ejecuta un comando del monitor humano (HMP), como
+// hostfwd_add, y devuelve su salida
+func (c *QMPClient) HumanMonitorCommand(cmd string) (string, error) {
+	var out string
+	err := c.Execute("human-monitor-command", map[string]string{"command-line": cmd}, &out)
+	return out, err
+}
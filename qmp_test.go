@@ -0,0 +1,142 @@
+package goqemu
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeQMPServer simula el monitor QMP de QEMU sobre un socket unix.
+// handler recibe el comando y devuelve el valor de "return" o un *QMPError.
+func fakeQMPServer(t *testing.T, handler func(cmd string, args json.RawMessage) any) (string, chan<- string) {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "qmp.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Error creando socket: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	events := make(chan string, 10)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		enc := json.NewEncoder(conn)
+		enc.Encode(map[string]any{"QMP": map[string]any{
+			"version":      map[string]any{"qemu": map[string]int{"major": 8, "minor": 2, "micro": 1}},
+			"capabilities": []string{"oob"},
+		}})
+
+		go func() {
+			for name := range events {
+				enc.Encode(map[string]any{
+					"event":     name,
+					"data":      map[string]any{"reason": "guest-shutdown"},
+					"timestamp": map[string]int64{"seconds": 1700000000, "microseconds": 0},
+				})
+			}
+		}()
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			var req struct {
+				Execute   string          `json:"execute"`
+				Arguments json.RawMessage `json:"arguments"`
+				ID        string          `json:"id"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+				return
+			}
+
+			var result any = map[string]any{}
+			if req.Execute != "qmp_capabilities" {
+				result = handler(req.Execute, req.Arguments)
+			}
+
+			if qerr, ok := result.(*QMPError); ok {
+				enc.Encode(map[string]any{"error": qerr, "id": req.ID})
+				continue
+			}
+			enc.Encode(map[string]any{"return": result, "id": req.ID})
+		}
+	}()
+
+	return socketPath, events
+}
+
+func TestQMPClient(t *testing.T) {
+	socketPath, events := fakeQMPServer(t, func(cmd string, args json.RawMessage) any {
+		switch cmd {
+		case "query-status":
+			return map[string]any{"running": true, "status": "running"}
+		case "query-block":
+			return []map[string]any{{
+				"device": "ide0-hd0",
+				"inserted": map[string]any{
+					"file": "/tmp/disk.qcow2",
+					"drv":  "qcow2",
+				},
+			}}
+		case "stop", "cont", "system_powerdown":
+			return map[string]any{}
+		default:
+			return &QMPError{Class: "CommandNotFound", Desc: "The command " + cmd + " has not been found"}
+		}
+	})
+
+	qmp, err := DialQMP(socketPath, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Error conectando QMP: %v", err)
+	}
+	defer qmp.Close()
+
+	if qmp.Greeting.QMP.Version.Qemu.Major != 8 {
+		t.Errorf("Versión del saludo incorrecta: %+v", qmp.Greeting)
+	}
+
+	status, err := qmp.QueryStatus()
+	if err != nil {
+		t.Fatalf("Error en query-status: %v", err)
+	}
+	if !status.Running || status.Status != "running" {
+		t.Errorf("Estado incorrecto: %+v", status)
+	}
+
+	devices, err := qmp.QueryBlock()
+	if err != nil {
+		t.Fatalf("Error en query-block: %v", err)
+	}
+	if len(devices) != 1 || devices[0].Inserted == nil || devices[0].Inserted.Driver != "qcow2" {
+		t.Errorf("Dispositivos incorrectos: %+v", devices)
+	}
+
+	if err := qmp.Stop(); err != nil {
+		t.Errorf("Error en stop: %v", err)
+	}
+
+	err = qmp.Execute("no-existe", nil, nil)
+	qerr, ok := err.(*QMPError)
+	if !ok || qerr.Class != "CommandNotFound" {
+		t.Errorf("Se esperaba QMPError CommandNotFound, obtenido: %v", err)
+	}
+
+	events <- "SHUTDOWN"
+	select {
+	case ev := <-qmp.Events():
+		if ev.Name != "SHUTDOWN" || ev.Data["reason"] != "guest-shutdown" {
+			t.Errorf("Evento incorrecto: %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout esperando evento QMP")
+	}
+	close(events)
+}
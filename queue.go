@@ -0,0 +1,213 @@
+package goqemu
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+const (
+	DefaultMaxCommands = 4   // Comandos de la cola ejecutados a la vez por defecto
+	commandQueueSize   = 100 // Comandos pendientes antes de bloquear Submit
+)
+
+// ErrCommandQueueClosed se devuelve para los comandos encolados que no
+// llegaron a ejecutarse porque la VM se detuvo
+var ErrCommandQueueClosed = errors.New("cola de comandos cerrada")
+
+// CommandFuture es el resultado pendiente de un comando enviado con Submit
+// o SubmitSerial
+type CommandFuture struct {
+	Command string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	result SshCommand
+}
+
+// Done devuelve un canal que se cierra cuando el comando termina
+func (f *CommandFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait bloquea hasta que el comando termina y devuelve su resultado
+func (f *CommandFuture) Wait() SshCommand {
+	<-f.done
+	return f.result
+}
+
+// Cancel cancela el comando: si no empezó no se ejecuta y si está en
+// ejecución se termina el proceso remoto
+func (f *CommandFuture) Cancel() {
+	if f.cancel != nil {
+		f.cancel()
+	}
+}
+
+func (f *CommandFuture) resolve(result SshCommand) {
+	f.result = result
+	close(f.done)
+	f.Cancel()
+}
+
+// failedFuture devuelve un futuro ya resuelto con err
+func failedFuture(cmd string, err error) *CommandFuture {
+	f := &CommandFuture{Command: cmd, done: make(chan struct{})}
+	f.resolve(SshCommand{Command: cmd, ExitStatus: -1, Err: err})
+	return f
+}
+
+// commandQueue ejecuta en segundo plano los comandos de una ejecución de la
+// VM sobre la conexión SSH compartida. commandChan alimenta a los workers
+// paralelos y serialChan a un único worker que respeta el orden de envío.
+type commandQueue struct {
+	commandChan chan *CommandFuture
+	serialChan  chan *CommandFuture
+	sem         chan struct{} // limita los comandos simultáneos de ambas colas
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Submit encola un comando para ejecutarlo en paralelo con otros, hasta
+// MaxConcurrentCommands a la vez. El orden de ejecución no está garantizado.
+func (vm *QemuVM) Submit(cmd string) *CommandFuture {
+	return vm.submit(cmd, false)
+}
+
+// SubmitSerial encola un comando en la cola serie: los comandos enviados
+// con SubmitSerial se ejecutan de uno en uno en el orden de envío
+func (vm *QemuVM) SubmitSerial(cmd string) *CommandFuture {
+	return vm.submit(cmd, true)
+}
+
+func (vm *QemuVM) submit(cmd string, serial bool) *CommandFuture {
+	if err := vm.checkSSHAvailable("Submit"); err != nil {
+		return failedFuture(cmd, err)
+	}
+
+	// El bloqueo de lectura impide que stopQueue vacíe la cola mientras
+	// se está encolando
+	vm.queueMu.RLock()
+	defer vm.queueMu.RUnlock()
+
+	q := vm.queue
+	if q == nil {
+		return failedFuture(cmd, ErrCommandQueueClosed)
+	}
+
+	f := &CommandFuture{Command: cmd, done: make(chan struct{})}
+	f.ctx, f.cancel = context.WithCancel(q.ctx)
+
+	ch := q.commandChan
+	if serial {
+		ch = q.serialChan
+	}
+
+	select {
+	case ch <- f:
+	case <-q.ctx.Done():
+		f.resolve(SshCommand{Command: cmd, ExitStatus: -1, Err: ErrCommandQueueClosed})
+	}
+	return f
+}
+
+// startQueue arranca los workers de la cola de comandos de esta ejecución
+func (vm *QemuVM) startQueue() {
+	workers := DefaultMaxCommands
+	if vm.config != nil && vm.config.MaxConcurrentCommands > 0 {
+		workers = vm.config.MaxConcurrentCommands
+	}
+
+	q := &commandQueue{
+		commandChan: make(chan *CommandFuture, commandQueueSize),
+		serialChan:  make(chan *CommandFuture, commandQueueSize),
+		sem:         make(chan struct{}, workers),
+	}
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go vm.commandWorker(q, q.commandChan)
+	}
+	q.wg.Add(1)
+	go vm.commandWorker(q, q.serialChan)
+
+	vm.queueMu.Lock()
+	vm.queue = q
+	vm.queueMu.Unlock()
+}
+
+// stopQueue cierra la cola: los comandos en ejecución se cancelan, los
+// pendientes se resuelven con ErrCommandQueueClosed y se espera a que
+// terminen todos los workers. Todos los futuros quedan resueltos.
+func (vm *QemuVM) stopQueue() {
+	vm.queueMu.RLock()
+	q := vm.queue
+	vm.queueMu.RUnlock()
+	if q == nil {
+		return
+	}
+
+	// Cancelar primero para desbloquear los Submit que esperan sitio
+	q.cancel()
+
+	vm.queueMu.Lock()
+	vm.queue = nil
+	vm.queueMu.Unlock()
+
+	q.wg.Wait()
+
+	for _, ch := range []chan *CommandFuture{q.commandChan, q.serialChan} {
+		for {
+			select {
+			case f := <-ch:
+				f.resolve(SshCommand{Command: f.Command, ExitStatus: -1, Err: ErrCommandQueueClosed})
+				continue
+			default:
+			}
+			break
+		}
+	}
+}
+
+// commandWorker ejecuta los comandos de ch hasta que se cierra la cola
+func (vm *QemuVM) commandWorker(q *commandQueue, ch chan *CommandFuture) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case f := <-ch:
+			vm.runQueued(q, f)
+		}
+	}
+}
+
+func (vm *QemuVM) runQueued(q *commandQueue, f *CommandFuture) {
+	if q.ctx.Err() != nil {
+		f.resolve(SshCommand{Command: f.Command, ExitStatus: -1, Err: ErrCommandQueueClosed})
+		return
+	}
+
+	select {
+	case q.sem <- struct{}{}:
+		defer func() { <-q.sem }()
+	case <-f.ctx.Done():
+	}
+
+	if err := f.ctx.Err(); err != nil {
+		if q.ctx.Err() != nil {
+			err = ErrCommandQueueClosed
+		}
+		f.resolve(SshCommand{Command: f.Command, ExitStatus: -1, Err: err})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(f.ctx, vm.commandTimeout())
+	defer cancel()
+	f.resolve(vm.SendCommandContext(ctx, f.Command))
+}
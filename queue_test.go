@@ -0,0 +1,99 @@
+package goqemu
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubmitParallel(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+	vm.config.MaxConcurrentCommands = 2
+	vm.startQueue()
+	defer vm.stopQueue()
+
+	start := time.Now()
+	var futures []*CommandFuture
+	for i := 0; i < 4; i++ {
+		futures = append(futures, vm.Submit(fmt.Sprintf("sleep 0.3; echo %d", i)))
+	}
+
+	for i, f := range futures {
+		cmd := f.Wait()
+		if cmd.Err != nil {
+			t.Fatalf("Error en comando %d: %v", i, cmd.Err)
+		}
+		if cmd.Stdout != fmt.Sprintf("%d\n", i) {
+			t.Errorf("Salida del comando %d: %q", i, cmd.Stdout)
+		}
+	}
+
+	// 4 comandos de 0.3s con 2 a la vez: dos tandas
+	elapsed := time.Since(start)
+	if elapsed < 600*time.Millisecond {
+		t.Errorf("Se ejecutaron más de 2 comandos a la vez: %v", elapsed)
+	}
+	if elapsed > 1100*time.Millisecond {
+		t.Errorf("Los comandos no se ejecutaron en paralelo: %v", elapsed)
+	}
+}
+
+func TestSubmitSerialOrder(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+	vm.startQueue()
+	defer vm.stopQueue()
+
+	out := filepath.Join(t.TempDir(), "orden.txt")
+	var futures []*CommandFuture
+	for i := 0; i < 5; i++ {
+		// Los primeros tardan más: sin orden serie terminarían al final
+		cmd := fmt.Sprintf("sleep 0.0%d; echo %d >> %s", 5-i, i, out)
+		futures = append(futures, vm.SubmitSerial(cmd))
+	}
+	for _, f := range futures {
+		if cmd := f.Wait(); cmd.Err != nil {
+			t.Fatalf("Error en comando: %v", cmd.Err)
+		}
+	}
+
+	cmd := vm.SendCommand("cat " + out)
+	if got := strings.Fields(cmd.Stdout); strings.Join(got, ",") != "0,1,2,3,4" {
+		t.Errorf("Orden esperado 0,1,2,3,4, obtenido %v", got)
+	}
+}
+
+func TestStopQueueDrains(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+	vm.startQueue()
+
+	running := vm.SubmitSerial("sleep 5")
+	pending := vm.SubmitSerial("echo nunca")
+	time.Sleep(100 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		vm.stopQueue()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stopQueue no terminó")
+	}
+
+	if cmd := running.Wait(); cmd.Err == nil {
+		t.Error("El comando en ejecución debería cancelarse")
+	}
+	if cmd := pending.Wait(); !errors.Is(cmd.Err, ErrCommandQueueClosed) {
+		t.Errorf("Se esperaba ErrCommandQueueClosed, obtenido: %v", cmd.Err)
+	}
+
+	// Tras detener la cola los envíos fallan de inmediato
+	if cmd := vm.Submit("true").Wait(); !errors.Is(cmd.Err, ErrCommandQueueClosed) {
+		t.Errorf("Se esperaba ErrCommandQueueClosed, obtenido: %v", cmd.Err)
+	}
+}
@@ -0,0 +1,207 @@
+package goqemu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultReadyTimeout = 2 * time.Minute // Tiempo máximo de Start para que la VM esté lista
+	readyPollInterval   = time.Second     // Espera entre intentos de una sonda
+)
+
+// Probe es una comprobación de que el invitado está listo. Check devuelve
+// nil cuando lo está; WaitReady la repite hasta entonces.
+type Probe struct {
+	Name  string
+	Check func(ctx context.Context, vm *QemuVM) error
+}
+
+// ProbeSSH comprueba que el handshake SSH con el invitado se completa y
+// deja la conexión abierta para el resto de sondas
+func ProbeSSH() Probe {
+	return Probe{
+		Name: "ssh",
+		Check: func(ctx context.Context, vm *QemuVM) error {
+			return vm.readyConn(ctx)
+		},
+	}
+}
+
+// ProbeCloudInit comprueba que cloud-init terminó. Un invitado sin
+// cloud-init se considera listo.
+func ProbeCloudInit() Probe {
+	return commandProbe("cloud-init", "cloud-init status", func(out string) (bool, error) {
+		switch {
+		case strings.Contains(out, "status: done"), strings.Contains(out, "status: disabled"):
+			return true, nil
+		case strings.Contains(out, "status: error"):
+			return false, errors.New("cloud-init terminó con errores")
+		}
+		return false, nil
+	})
+}
+
+// ProbeSystemd comprueba que systemctl is-system-running informa running o
+// degraded. Un invitado sin systemd se considera listo.
+func ProbeSystemd() Probe {
+	return commandProbe("systemd", "systemctl is-system-running", func(out string) (bool, error) {
+		switch strings.TrimSpace(out) {
+		case "running", "degraded":
+			return true, nil
+		}
+		return false, nil
+	})
+}
+
+// commandProbe ejecuta cmd en el invitado y decide con ready a partir de su
+// salida, que se evalúa aunque el código de salida no sea cero
+func commandProbe(name, cmd string, ready func(out string) (bool, error)) Probe {
+	return Probe{
+		Name: name,
+		Check: func(ctx context.Context, vm *QemuVM) error {
+			if err := vm.readyConn(ctx); err != nil {
+				return err
+			}
+
+			res := vm.runCommand(ctx, cmd, nil)
+			var exitErr *ExitError
+			if res.Err != nil && !errors.As(res.Err, &exitErr) {
+				return res.Err
+			}
+			if res.ExitStatus == 127 {
+				return nil // comando no instalado en el invitado
+			}
+
+			ok, err := ready(res.Stdout)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%s: %s", cmd, strings.TrimSpace(res.Stdout+res.Stderr))
+			}
+			return nil
+		},
+	}
+}
+
+// ProbeTCP comprueba que un puerto del invitado acepta conexiones. La
+// conexión se abre desde el invitado a través de SSH, así que no requiere
+// reenviar el puerto al host.
+func ProbeTCP(guestPort int) Probe {
+	return Probe{
+		Name: fmt.Sprintf("tcp:%d", guestPort),
+		Check: func(ctx context.Context, vm *QemuVM) error {
+			conn, err := vm.dialReady(ctx, guestPort)
+			if err != nil {
+				return err
+			}
+			conn.Close()
+			return nil
+		},
+	}
+}
+
+// ProbeHTTP comprueba que un servidor HTTP del invitado responde a GET path
+// con un código menor que 500
+func ProbeHTTP(guestPort int, path string) Probe {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return Probe{
+		Name: fmt.Sprintf("http:%d%s", guestPort, path),
+		Check: func(ctx context.Context, vm *QemuVM) error {
+			client := &http.Client{
+				Transport: &http.Transport{
+					DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+						return vm.dialReady(ctx, guestPort)
+					},
+					DisableKeepAlives: true,
+				},
+			}
+
+			url := fmt.Sprintf("http://127.0.0.1:%d%s", guestPort, path)
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode >= 500 {
+				return fmt.Errorf("GET %s respondió %s", path, resp.Status)
+			}
+			return nil
+		},
+	}
+}
+
+// readyConn abre la conexión SSH si todavía no existe
+func (vm *QemuVM) readyConn(ctx context.Context) error {
+	if vm.currentSSH() != nil {
+		return nil
+	}
+	return vm.connectSSHContext(ctx)
+}
+
+// dialReady abre una conexión al puerto del invitado a través de SSH
+func (vm *QemuVM) dialReady(ctx context.Context, guestPort int) (net.Conn, error) {
+	if err := vm.readyConn(ctx); err != nil {
+		return nil, err
+	}
+	client := vm.currentSSH()
+	if client == nil {
+		return nil, &ConnError{Op: "dial", Err: errors.New("no hay conexión SSH con la VM")}
+	}
+	return client.DialContext(ctx, "tcp", fmt.Sprintf("127.0.0.1:%d", guestPort))
+}
+
+// WaitReady espera a que todas las sondas se cumplan, en orden, o a que se
+// cancele ctx. Sin sondas espera al handshake SSH. El error indica la
+// sonda que no se cumplió y su último fallo.
+func (vm *QemuVM) WaitReady(ctx context.Context, probes ...Probe) error {
+	if len(probes) == 0 {
+		probes = []Probe{ProbeSSH()}
+	}
+
+	for _, p := range probes {
+		if err := vm.waitProbe(ctx, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitProbe repite la sonda hasta que se cumple, se cancela ctx o QEMU termina
+func (vm *QemuVM) waitProbe(ctx context.Context, p Probe) error {
+	for {
+		err := p.Check(ctx, vm)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("la VM no está lista (%s): %v: %w", p.Name, err, ctx.Err())
+		case <-vm.Done():
+			return fmt.Errorf("la VM no está lista (%s): QEMU terminó", p.Name)
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
+
+// readyTimeout devuelve el tiempo máximo de Start para que la VM esté lista
+func (vm *QemuVM) readyTimeout() time.Duration {
+	if vm.config != nil && vm.config.ReadyTimeout > 0 {
+		return vm.config.ReadyTimeout
+	}
+	return DefaultReadyTimeout
+}
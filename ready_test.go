@@ -0,0 +1,112 @@
+package goqemu
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitReadySSH(t *testing.T) {
+	srv := newFakeSSHServer(t)
+	vm := &QemuVM{config: &QemuConfig{}, state: StateStarting, workDir: t.TempDir(), id: "vm-test"}
+	if err := connectTo(t, vm, srv); err != nil {
+		t.Fatalf("Error conectando: %v", err)
+	}
+	vm.closeSSH()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := vm.WaitReady(ctx); err != nil {
+		t.Fatalf("WaitReady: %v", err)
+	}
+	if vm.currentSSH() == nil {
+		t.Error("ProbeSSH debería dejar la conexión abierta")
+	}
+}
+
+func TestWaitReadyHandshakeTimeout(t *testing.T) {
+	// Como slirp: acepta la conexión TCP pero sshd todavía no responde
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	vm := &QemuVM{config: &QemuConfig{}, state: StateStarting, workDir: t.TempDir(), id: "vm-test"}
+	vm.sshPort = ln.Addr().(*net.TCPAddr).Port
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = vm.WaitReady(ctx, ProbeSSH())
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "ssh") {
+		t.Fatalf("Se esperaba timeout de la sonda ssh, obtenido: %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("WaitReady no respetó el contexto: %v", time.Since(start))
+	}
+}
+
+func TestWaitReadyPorts(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer web.Close()
+	port, _ := strconv.Atoi(web.URL[strings.LastIndex(web.URL, ":")+1:])
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := vm.WaitReady(ctx, ProbeTCP(port), ProbeHTTP(port, "health")); err != nil {
+		t.Fatalf("WaitReady: %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	err := vm.WaitReady(ctx, ProbeHTTP(port, "/"))
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Se esperaba fallo por el código 503, obtenido: %v", err)
+	}
+}
+
+func TestWaitReadySystemd(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+
+	// systemctl falso: "starting" en la primera llamada y "running" después
+	dir := t.TempDir()
+	script := "#!/bin/sh\nif [ -e " + dir + "/llamado ]; then echo running; exit 0; fi\n" +
+		"touch " + dir + "/llamado\necho starting\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(dir, "systemctl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := vm.WaitReady(ctx, ProbeSystemd()); err != nil {
+		t.Fatalf("WaitReady: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "llamado")); err != nil {
+		t.Error("La sonda no ejecutó systemctl")
+	}
+}
@@ -0,0 +1,227 @@
+package goqemu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	DefaultKeepaliveInterval = 15 * time.Second // Intervalo entre keepalives SSH
+	DefaultReconnectTimeout  = 60 * time.Second // Tiempo máximo para reconectar SSH
+
+	reconnectBackoffMin = 250 * time.Millisecond
+	reconnectBackoffMax = 5 * time.Second
+)
+
+// ReconnectEvent describe una reconexión SSH con el invitado, p. ej. tras
+// reiniciar el invitado o restaurar un snapshot
+type ReconnectEvent struct {
+	Attempts int           // intentos de conexión hasta conseguirla
+	Downtime time.Duration // tiempo sin conexión desde que se detectó el fallo
+}
+
+// OnReconnect registra una función que se llama cada vez que se restablece
+// la conexión SSH con el invitado
+func (vm *QemuVM) OnReconnect(fn func(ReconnectEvent)) {
+	vm.sshMu.Lock()
+	defer vm.sshMu.Unlock()
+	vm.onReconnect = append(vm.onReconnect, fn)
+}
+
+// currentSSH devuelve el cliente SSH actual, nil si no hay conexión
+func (vm *QemuVM) currentSSH() *ssh.Client {
+	vm.sshMu.Lock()
+	defer vm.sshMu.Unlock()
+	return vm.sshClient
+}
+
+// setSSHClient registra un cliente SSH nuevo y arranca su keepalive
+func (vm *QemuVM) setSSHClient(client *ssh.Client) {
+	vm.sshMu.Lock()
+	old := vm.sshClient
+	vm.sshClient = client
+	vm.sshMu.Unlock()
+
+	if old != nil && old != client {
+		old.Close()
+	}
+
+	go vm.keepalive(client, vm.keepaliveInterval())
+}
+
+// closeSSH cierra la conexión SSH sin intentar reconectar
+func (vm *QemuVM) closeSSH() {
+	vm.sshMu.Lock()
+	client := vm.sshClient
+	vm.sshClient = nil
+	vm.sshMu.Unlock()
+
+	if client != nil {
+		client.Close()
+	}
+}
+
+func (vm *QemuVM) keepaliveInterval() time.Duration {
+	if vm.config != nil && vm.config.KeepaliveInterval > 0 {
+		return vm.config.KeepaliveInterval
+	}
+	return DefaultKeepaliveInterval
+}
+
+func (vm *QemuVM) reconnectTimeout() time.Duration {
+	if vm.config != nil && vm.config.ReconnectTimeout > 0 {
+		return vm.config.ReconnectTimeout
+	}
+	return DefaultReconnectTimeout
+}
+
+// keepalive envía peticiones keepalive@openssh.com cada interval. Si el
+// invitado no responde a tiempo o la conexión se cierra sin que la VM se
+// haya detenido, reconecta en segundo plano.
+func (vm *QemuVM) keepalive(client *ssh.Client, interval time.Duration) {
+	closed := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			vm.connectionLost(client)
+			return
+		case <-ticker.C:
+			if err := sendKeepalive(client, interval); err != nil {
+				client.Close()
+				vm.connectionLost(client)
+				return
+			}
+		}
+	}
+}
+
+// sendKeepalive envía un keepalive y espera la respuesta como máximo timeout
+func sendKeepalive(client *ssh.Client, timeout time.Duration) error {
+	reply := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		reply <- err
+	}()
+
+	select {
+	case err := <-reply:
+		return err
+	case <-time.After(timeout):
+		return errors.New("el invitado no respondió al keepalive")
+	}
+}
+
+// runContext devuelve el contexto de la ejecución actual de la VM, que se
+// cancela al detenerla
+func (vm *QemuVM) runContext() context.Context {
+	if vm.ctx == nil {
+		return context.Background()
+	}
+	return vm.ctx
+}
+
+// connectionLost reconecta en segundo plano si client sigue siendo la
+// conexión actual, es decir, no se cerró a propósito con closeSSH
+func (vm *QemuVM) connectionLost(client *ssh.Client) {
+	if vm.currentSSH() != client {
+		return
+	}
+
+	vm.reconnectSSH(vm.runContext(), client)
+}
+
+// lockReconnect espera hasta ser la única reconexión en curso o hasta que
+// se cancele ctx
+func (vm *QemuVM) lockReconnect(ctx context.Context) error {
+	vm.sshMu.Lock()
+	if vm.reconnecting == nil {
+		vm.reconnecting = make(chan struct{}, 1)
+	}
+	sem := vm.reconnecting
+	vm.sshMu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (vm *QemuVM) unlockReconnect() {
+	vm.sshMu.Lock()
+	sem := vm.reconnecting
+	vm.sshMu.Unlock()
+	<-sem
+}
+
+// reconnectSSH sustituye la conexión rota broken por una nueva, reintentando
+// con espera exponencial hasta ReconnectTimeout o hasta que se cancele ctx.
+// Si otra llamada ya reconectó devuelve nil sin volver a conectar.
+func (vm *QemuVM) reconnectSSH(ctx context.Context, broken *ssh.Client) error {
+	// Esperar a la reconexión en curso sin sobrepasar ctx
+	if err := vm.lockReconnect(ctx); err != nil {
+		return &ConnError{Op: "reconnect", Err: err}
+	}
+	defer vm.unlockReconnect()
+
+	if current := vm.currentSSH(); current != nil && current != broken {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, vm.reconnectTimeout())
+	defer cancel()
+
+	start := time.Now()
+	backoff := reconnectBackoffMin
+	attempts := 0
+
+	for {
+		attempts++
+		err := vm.connectSSHContext(ctx)
+		if err == nil {
+			break
+		}
+
+		// La VM se detuvo o se pausó: no tiene sentido seguir intentando
+		var stateErr *StateError
+		if errors.Is(err, ErrVMPaused) || errors.As(err, &stateErr) {
+			return &ConnError{Op: "reconnect", Err: err}
+		}
+
+		select {
+		case <-ctx.Done():
+			return &ConnError{Op: "reconnect", Err: fmt.Errorf("%d intentos: %v", attempts, err)}
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > reconnectBackoffMax {
+			backoff = reconnectBackoffMax
+		}
+	}
+
+	event := ReconnectEvent{Attempts: attempts, Downtime: time.Since(start)}
+
+	vm.sshMu.Lock()
+	hooks := make([]func(ReconnectEvent), len(vm.onReconnect))
+	copy(hooks, vm.onReconnect)
+	vm.sshMu.Unlock()
+
+	for _, fn := range hooks {
+		fn(event)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package goqemu
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReconnectAfterConnectionLost(t *testing.T) {
+	srv := newFakeSSHServer(t)
+	vm := &QemuVM{config: &QemuConfig{}, state: StateRunning, workDir: t.TempDir(), id: "vm-test"}
+
+	events := make(chan ReconnectEvent, 4)
+	vm.OnReconnect(func(e ReconnectEvent) { events <- e })
+
+	if err := connectTo(t, vm, srv); err != nil {
+		t.Fatalf("Error conectando: %v", err)
+	}
+
+	// Simular que el invitado se reinició y la conexión se cortó
+	broken := vm.currentSSH()
+	broken.Close()
+
+	select {
+	case e := <-events:
+		if e.Attempts < 1 {
+			t.Errorf("Evento de reconexión inesperado: %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No se reconectó tras perder la conexión")
+	}
+
+	if vm.currentSSH() == broken {
+		t.Fatal("La conexión rota no se sustituyó")
+	}
+	if cmd := vm.SendCommand("echo ok"); cmd.Err != nil || cmd.Stdout != "ok\n" {
+		t.Errorf("Comando tras reconectar: %q, %v", cmd.Stdout, cmd.Err)
+	}
+}
+
+func TestSendCommandReconnects(t *testing.T) {
+	srv := newFakeSSHServer(t)
+	vm := &QemuVM{config: &QemuConfig{}, state: StateRunning, workDir: t.TempDir(), id: "vm-test"}
+	if err := connectTo(t, vm, srv); err != nil {
+		t.Fatalf("Error conectando: %v", err)
+	}
+
+	// closeSSH no reconecta: la siguiente orden lo hace de forma transparente
+	vm.closeSSH()
+	time.Sleep(50 * time.Millisecond)
+	if vm.currentSSH() != nil {
+		t.Fatal("closeSSH no debería reconectar en segundo plano")
+	}
+
+	reconnected := make(chan struct{}, 1)
+	vm.OnReconnect(func(ReconnectEvent) { reconnected <- struct{}{} })
+
+	if cmd := vm.SendCommand("echo ok"); cmd.Err != nil || cmd.Stdout != "ok\n" {
+		t.Fatalf("Comando con reconexión: %q, %v", cmd.Stdout, cmd.Err)
+	}
+
+	select {
+	case <-reconnected:
+	default:
+		t.Error("No se notificó la reconexión")
+	}
+}
+
+func TestReconnectHonorsContext(t *testing.T) {
+	// Un puerto que acepta conexiones pero nunca completa el handshake,
+	// como el reenvío de slirp antes de que arranque sshd
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	vm := &QemuVM{
+		config:  &QemuConfig{},
+		state:   StateRunning,
+		workDir: t.TempDir(),
+		sshPort: ln.Addr().(*net.TCPAddr).Port,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := vm.reconnectSSH(ctx, nil); err == nil {
+		t.Fatal("La reconexión debería fallar sin sshd")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("El intento de conexión ignoró ctx: %v", elapsed)
+	}
+
+	// Con otra reconexión en curso, un comando respeta su propio ctx
+	if err := vm.lockReconnect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer vm.unlockReconnect()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := vm.newSession(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Se esperaba context.DeadlineExceeded, obtenido %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package goqemu
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Runner abstrae la ejecución de los binarios externos que usa goqemu
+// (qemu-system-x86_64, qemu-img, ping...) para poder sustituirlos en pruebas
+type Runner interface {
+	// LookPath busca un binario en el PATH
+	LookPath(file string) (string, error)
+	// Start lanza un proceso sin esperar a que termine
+	Start(name string, args []string, stdout, stderr io.Writer) (Process, error)
+	// Output ejecuta un proceso y devuelve su salida estándar
+	Output(name string, args ...string) ([]byte, error)
+}
+
+// Process es un proceso lanzado por un Runner
+type Process interface {
+	Pid() int
+	// Wait espera a que termine el proceso. El error solo indica fallos al
+	// esperar, un código de salida distinto de cero se informa en ProcessExit.
+	Wait() (ProcessExit, error)
+	// Terminate solicita al proceso que termine (SIGTERM)
+	Terminate() error
+	// Kill fuerza la terminación del proceso (SIGKILL)
+	Kill() error
+}
+
+// ProcessExit describe la terminación de un proceso
+type ProcessExit struct {
+	Code   int    // código de salida, -1 si terminó por una señal
+	Reason string // p. ej. "exit status 1" o "signal: killed"
+}
+
+// ExecRunner es el Runner por defecto basado en os/exec
+type ExecRunner struct{}
+
+// LookPath busca un binario en el PATH
+func (ExecRunner) LookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+// Start lanza un proceso sin esperar a que termine
+func (ExecRunner) Start(name string, args []string, stdout, stderr io.Writer) (Process, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &execProcess{cmd: cmd}, nil
+}
+
+// Output ejecuta un proceso y devuelve su salida estándar
+func (ExecRunner) Output(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+// execProcess es un Process respaldado por exec.Cmd
+type execProcess struct {
+	cmd *exec.Cmd
+}
+
+func (p *execProcess) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+func (p *execProcess) Wait() (ProcessExit, error) {
+	err := p.cmd.Wait()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return ProcessExit{Code: -1, Reason: err.Error()}, err
+	}
+
+	state := p.cmd.ProcessState
+	exit := ProcessExit{Code: state.ExitCode(), Reason: state.String()}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		exit.Reason = "signal: " + ws.Signal().String()
+	}
+	return exit, nil
+}
+
+func (p *execProcess) Terminate() error {
+	return terminateProcess(p.cmd.Process)
+}
+
+func (p *execProcess) Kill() error {
+	err := p.cmd.Process.Kill()
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+	return err
+}
+
+// runnerOrDefault devuelve el Runner configurado de la VM o el predeterminado
+func (vm *QemuVM) runnerOrDefault() Runner {
+	if vm.runner != nil {
+		return vm.runner
+	}
+	return ExecRunner{}
+}
@@ -0,0 +1,130 @@
+package goqemu
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultInterpreter = "/bin/sh" // Intérprete por defecto de RunScript
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ScriptOptions configura la ejecución de RunScript
+type ScriptOptions struct {
+	Interpreter  string            // intérprete del script, default /bin/sh
+	Env          map[string]string // variables de entorno adicionales
+	Dir          string            // directorio de trabajo, default el home del usuario
+	Sudo         bool              // ejecutar el script con sudo
+	SudoPassword string            // contraseña de sudo, default SSHPassword de la configuración
+	Timeout      time.Duration     // tiempo máximo, default CommandTimeout
+}
+
+// RunScript sube script a un archivo temporal de la VM, lo ejecuta con el
+// intérprete, entorno, directorio y sudo indicados y lo borra al terminar.
+// Devuelve el mismo resultado que SendCommand.
+func (vm *QemuVM) RunScript(script string, opts ScriptOptions) SshCommand {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = vm.commandTimeout()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return vm.RunScriptContext(ctx, script, opts)
+}
+
+// RunScriptContext es RunScript con cancelación por contexto
+func (vm *QemuVM) RunScriptContext(ctx context.Context, script string, opts ScriptOptions) SshCommand {
+	if err := vm.checkSSHAvailable("RunScript"); err != nil {
+		return SshCommand{ExitStatus: -1, Err: err}
+	}
+
+	// Subir el script a un archivo temporal solo legible por el usuario
+	upload := vm.runCommand(ctx, `f=$(mktemp /tmp/goqemu-script.XXXXXX) && cat > "$f" && chmod 700 "$f" && echo "$f"`,
+		strings.NewReader(script))
+	if upload.Err != nil {
+		upload.Err = fmt.Errorf("error subiendo el script: %w", upload.Err)
+		return upload
+	}
+	path := strings.TrimSpace(upload.Stdout)
+
+	// Borrar el script aunque ctx se haya cancelado
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		vm.runCommand(cleanupCtx, "rm -f "+shellQuote(path), nil)
+	}()
+
+	// Con contraseña, sudo -S solo la recibe si la pide: sin pedirla
+	// llegaría a la entrada del script
+	password := ""
+	if opts.Sudo {
+		password = opts.SudoPassword
+		if password == "" && vm.config != nil {
+			password = vm.config.SSHPassword
+		}
+	}
+
+	cmd, err := scriptCommand(path, opts, password != "")
+	if err != nil {
+		return SshCommand{ExitStatus: -1, Err: err}
+	}
+
+	var stdin io.Reader
+	if password != "" {
+		stdin = strings.NewReader(password + "\n")
+	}
+	return vm.runCommand(ctx, cmd, stdin)
+}
+
+// scriptCommand construye la línea de comando que ejecuta el script en path.
+// Con sudo el cambio de directorio se hace en la shell de root, así funcionan
+// los directorios que solo root puede abrir. Con stdinPassword la primera
+// línea de stdin es la contraseña: se usa sudo -n si no hace falta, p. ej.
+// con NOPASSWD o credenciales en caché, y si no se entrega a sudo -S.
+func scriptCommand(path string, opts ScriptOptions, stdinPassword bool) (string, error) {
+	interpreter := opts.Interpreter
+	if interpreter == "" {
+		interpreter = defaultInterpreter
+	}
+
+	var parts []string
+	if opts.Dir != "" {
+		parts = append(parts, "cd", shellQuote(opts.Dir), "&&")
+	}
+
+	// env después de sudo: sudo limpia el entorno
+	if len(opts.Env) > 0 {
+		keys := make([]string, 0, len(opts.Env))
+		for k := range opts.Env {
+			if !envNameRe.MatchString(k) {
+				return "", fmt.Errorf("nombre de variable de entorno inválido: %q", k)
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		parts = append(parts, "env")
+		for _, k := range keys {
+			parts = append(parts, shellQuote(k+"="+opts.Env[k]))
+		}
+	}
+
+	parts = append(parts, shellQuote(interpreter), shellQuote(path))
+	cmd := strings.Join(parts, " ")
+	if !opts.Sudo {
+		return cmd, nil
+	}
+
+	rootCmd := "-- /bin/sh -c " + shellQuote(cmd)
+	if !stdinPassword {
+		return "sudo -n " + rootCmd, nil
+	}
+	return `IFS= read -r pw; if sudo -n true 2>/dev/null; then sudo -n ` + rootCmd +
+		`; else printf '%s\n' "$pw" | sudo -S -p '' ` + rootCmd + `; fi`, nil
+}
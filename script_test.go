@@ -0,0 +1,117 @@
+package goqemu
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunScript(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+	dir := t.TempDir()
+
+	script := "echo \"$0\"\npwd\necho \"$SALUDO $DESTINO\"\n"
+	cmd := vm.RunScript(script, ScriptOptions{
+		Interpreter: "sh",
+		Env:         map[string]string{"SALUDO": "hola", "DESTINO": "mundo con espacios"},
+		Dir:         dir,
+	})
+	if cmd.Err != nil {
+		t.Fatalf("Error ejecutando el script: %v", cmd.Err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(cmd.Stdout), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Salida inesperada: %q", cmd.Stdout)
+	}
+	if lines[1] != dir {
+		t.Errorf("Directorio esperado %q, obtenido %q", dir, lines[1])
+	}
+	if lines[2] != "hola mundo con espacios" {
+		t.Errorf("Variables de entorno no aplicadas: %q", lines[2])
+	}
+
+	// El script temporal se borra al terminar
+	if _, err := os.Stat(lines[0]); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("El script temporal %s no se borró: %v", lines[0], err)
+	}
+}
+
+func TestRunScriptExitError(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+
+	cmd := vm.RunScript("echo fallo >&2\nexit 4\n", ScriptOptions{})
+	var exitErr *ExitError
+	if !errors.As(cmd.Err, &exitErr) || exitErr.ExitStatus != 4 {
+		t.Fatalf("Se esperaba *ExitError con código 4, obtenido %v", cmd.Err)
+	}
+	if cmd.Stderr != "fallo\n" {
+		t.Errorf("Stderr esperado %q, obtenido %q", "fallo\n", cmd.Stderr)
+	}
+
+	if cmd := vm.RunScript("true", ScriptOptions{Env: map[string]string{"MAL NOMBRE": "x"}}); cmd.Err == nil {
+		t.Error("Se esperaba error con un nombre de variable inválido")
+	}
+}
+
+func TestScriptCommandSudo(t *testing.T) {
+	opts := ScriptOptions{Sudo: true, Env: map[string]string{"A": "1"}, Dir: "/srv"}
+
+	cmd, err := scriptCommand("/tmp/s", opts, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd != "sudo -n -- /bin/sh -c 'cd /srv && env A=1 /bin/sh /tmp/s'" {
+		t.Errorf("Comando sin contraseña inesperado: %q", cmd)
+	}
+
+	cmd, err = scriptCommand("/tmp/s", opts, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"sudo -n -- /bin/sh -c 'cd /srv", "sudo -S -p '' -- /bin/sh -c 'cd /srv"} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("Falta %q en el comando con contraseña: %q", want, cmd)
+		}
+	}
+}
+
+// fakeSudo pide la contraseña "secreto" salvo que FAKE_SUDO_NOPASSWD esté
+// definida, como un usuario con NOPASSWD o con credenciales en caché
+const fakeSudo = `#!/bin/sh
+mode=$1; shift
+[ "$mode" = -S ] && shift 2
+[ "$1" = -- ] && shift
+if [ -z "$FAKE_SUDO_NOPASSWD" ]; then
+	[ "$mode" = -S ] || { echo "sudo: a password is required" >&2; exit 1; }
+	IFS= read -r pw
+	[ "$pw" = secreto ] || { echo "sudo: incorrect password" >&2; exit 1; }
+fi
+exec "$@"
+`
+
+func TestRunScriptSudoPassword(t *testing.T) {
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "sudo"), []byte(fakeSudo), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+	dir := t.TempDir()
+	opts := ScriptOptions{Sudo: true, SudoPassword: "secreto", Dir: dir}
+
+	// La contraseña nunca llega a la entrada del script, la pida sudo o no
+	for _, nopasswd := range []string{"", "1"} {
+		t.Setenv("FAKE_SUDO_NOPASSWD", nopasswd)
+		cmd := vm.RunScript("echo \"entrada: $(cat)\"\npwd\n", opts)
+		if cmd.Err != nil {
+			t.Fatalf("NOPASSWD=%q: %v, stderr %q", nopasswd, cmd.Err, cmd.Stderr)
+		}
+		if want := "entrada: \n" + dir + "\n"; cmd.Stdout != want {
+			t.Errorf("NOPASSWD=%q: salida %q, se esperaba %q", nopasswd, cmd.Stdout, want)
+		}
+	}
+}
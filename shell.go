@@ -0,0 +1,129 @@
+package goqemu
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// Tamaño de la terminal remota si la local no es una terminal
+const (
+	defaultTermWidth  = 80
+	defaultTermHeight = 24
+)
+
+// Shell abre una shell interactiva en la VM con una PTY del tamaño de la
+// terminal local. Si stdin es una terminal se pone en modo raw mientras
+// dura la shell y se restaura al salir; los cambios de tamaño de la
+// ventana se reenvían al invitado. Devuelve un *ExitError si la shell
+// termina con un código distinto de cero.
+//
+// Si stdin es un *os.File la copia hacia la shell se detiene al volver
+// Shell y la siguiente entrada queda para el llamador. En Windows, o con
+// otro io.Reader, la copia sigue esperando en Read y consume la siguiente
+// entrada de stdin.
+func (vm *QemuVM) Shell(stdin io.Reader, stdout, stderr io.Writer) error {
+	if err := vm.checkSSHAvailable("Shell"); err != nil {
+		return err
+	}
+
+	session, err := vm.newSession(context.Background())
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	// Terminal local: la de stdin para el modo raw y la de stdout para el tamaño
+	inFd, inTerm := terminalFd(stdin)
+	sizeFd, sizeTerm := terminalFd(stdout)
+	if !sizeTerm {
+		sizeFd, sizeTerm = inFd, inTerm
+	}
+
+	width, height := defaultTermWidth, defaultTermHeight
+	if sizeTerm {
+		if w, h, err := term.GetSize(sizeFd); err == nil {
+			width, height = w, h
+		}
+	}
+
+	termType := os.Getenv("TERM")
+	if termType == "" {
+		termType = "xterm-256color"
+	}
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := session.RequestPty(termType, height, width, modes); err != nil {
+		return &ConnError{Op: "pty", Err: err}
+	}
+
+	if inTerm {
+		state, err := term.MakeRaw(inFd)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(inFd, state)
+	}
+
+	if stdin != nil {
+		w, err := session.StdinPipe()
+		if err != nil {
+			return &ConnError{Op: "stdin", Err: err}
+		}
+		reader, stop, ok := cancelableReader(stdin)
+		copied := make(chan struct{})
+		go func() {
+			io.Copy(w, reader)
+			w.Close()
+			close(copied)
+		}()
+		// Dejar de leer stdin al terminar la shell
+		defer func() {
+			stop()
+			if ok {
+				session.Close()
+				<-copied
+			}
+		}()
+	}
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	if err := session.Shell(); err != nil {
+		return &ConnError{Op: "shell", Err: err}
+	}
+
+	if sizeTerm {
+		stop := watchWindowSize(sizeFd, func(w, h int) {
+			session.WindowChange(h, w)
+		})
+		defer stop()
+	}
+
+	err = session.Wait()
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Command: "shell", ExitStatus: exitErr.ExitStatus(), Signal: exitErr.Signal()}
+	}
+	if err != nil {
+		return &ConnError{Op: "wait", Err: err}
+	}
+	return nil
+}
+
+// terminalFd devuelve el descriptor de v si es un *os.File conectado a una terminal
+func terminalFd(v any) (int, bool) {
+	f, ok := v.(*os.File)
+	if !ok {
+		return 0, false
+	}
+	fd := int(f.Fd())
+	return fd, term.IsTerminal(fd)
+}
@@ -0,0 +1,76 @@
+package goqemu
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultShutdownTimeout = 30 * time.Second // Gracia por defecto para el apagado ACPI
+	escalationTimeout      = 5 * time.Second  // Espera tras quit, SIGTERM y SIGKILL
+)
+
+// StopMethod indica cómo terminó el proceso QEMU al detener la VM
+type StopMethod string
+
+const (
+	StopNotRunning StopMethod = "not-running" // el proceso ya había terminado
+	StopPowerdown  StopMethod = "powerdown"   // apagado ACPI ordenado del invitado
+	StopQuit       StopMethod = "quit"        // comando quit del monitor QMP
+	StopTerminate  StopMethod = "sigterm"     // señal SIGTERM al proceso
+	StopKill       StopMethod = "sigkill"     // señal SIGKILL al proceso
+)
+
+// pidFilePath devuelve la ruta del archivo pid de QEMU
+func (vm *QemuVM) pidFilePath() string {
+	return filepath.Join(vm.workDir, "qemu.pid")
+}
+
+// waitExit espera hasta timeout a que el proceso QEMU termine.
+// exited se cierra cuando supervise recoge el proceso.
+func (vm *QemuVM) waitExit(timeout time.Duration) bool {
+	select {
+	case <-vm.exited:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// stopProcess detiene únicamente el proceso QEMU de esta VM. Primero solicita
+// un apagado ACPI y espera grace, luego escala a quit, SIGTERM y SIGKILL.
+func (vm *QemuVM) stopProcess(grace time.Duration) (StopMethod, error) {
+	if vm.proc == nil {
+		return StopNotRunning, nil
+	}
+
+	if vm.waitExit(0) {
+		return StopNotRunning, nil
+	}
+
+	if qmp := vm.QMP(); qmp != nil {
+		// Apagado ordenado del sistema invitado
+		if err := qmp.SystemPowerdown(); err == nil && vm.waitExit(grace) {
+			return StopPowerdown, nil
+		}
+
+		// Terminar QEMU desde el monitor
+		if err := qmp.Quit(); err == nil && vm.waitExit(escalationTimeout) {
+			return StopQuit, nil
+		}
+	}
+
+	if err := vm.proc.Terminate(); err == nil && vm.waitExit(escalationTimeout) {
+		return StopTerminate, nil
+	}
+
+	if err := vm.proc.Kill(); err != nil {
+		return StopKill, fmt.Errorf("error matando proceso QEMU %d: %v", vm.proc.Pid(), err)
+	}
+	if !vm.waitExit(escalationTimeout) {
+		return StopKill, fmt.Errorf("el proceso QEMU %d no terminó", vm.proc.Pid())
+	}
+
+	return StopKill, nil
+}
@@ -1,10 +1,10 @@
 package goqemu
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"time"
@@ -62,8 +62,7 @@ func (vm *QemuVM) CreateSnapshot(description string) (*Snapshot, error) {
 	} else {
 		// Crear snapshot en disco
 		snapPath := filepath.Join(snapDir, snapshot.ID+".qcow2")
-		cmd := exec.Command("qemu-img", "snapshot", "-c", snapshot.ID, snapPath)
-		err := cmd.Run()
+		_, err := vm.runnerOrDefault().Output("qemu-img", "snapshot", "-c", snapshot.ID, snapPath)
 		if err != nil {
 			return nil, fmt.Errorf("error creando snapshot en disco: %v", err)
 		}
@@ -91,18 +90,19 @@ func (vm *QemuVM) RestoreSnapshot(id string) error {
 	} else {
 		// Restaurar snapshot en disco
 		snapPath := filepath.Join(snapDir, id+".qcow2")
-		cmd := exec.Command("qemu-img", "snapshot", "-a", id, snapPath)
-		err := cmd.Run()
+		_, err := vm.runnerOrDefault().Output("qemu-img", "snapshot", "-a", id, snapPath)
 		if err != nil {
 			return fmt.Errorf("error restaurando snapshot: %v", err)
 		}
 	}
 
-	// Reiniciar conexión SSH
-	if vm.sshClient != nil {
-		vm.sshClient.Close()
+	// Reiniciar conexión SSH. El snapshot puede tener otra clave de host.
+	broken := vm.currentSSH()
+	if broken != nil {
+		broken.Close()
 	}
-	err := vm.connectSSH()
+	vm.invalidateHostKey()
+	err := vm.reconnectSSH(context.Background(), broken)
 	if err != nil {
 		return fmt.Errorf("error reconectando SSH: %v", err)
 	}
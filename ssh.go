@@ -1,18 +1,66 @@
 package goqemu
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/ssh"
 )
 
+const DefaultCommandTimeout = 5 * time.Minute // Tiempo máximo por comando en SendCommand
+
+const sshHandshakeTimeout = 10 * time.Second // Tiempo máximo para conectar y negociar SSH
+
+// SshCommand es un comando ejecutado en la VM y su resultado
 type SshCommand struct {
-	Command string
-	Result  string
-	Err     error
+	Command    string
+	Result     string // salida estándar, igual que Stdout
+	Stdout     string
+	Stderr     string
+	ExitStatus int // código de salida, -1 si el comando no llegó a terminar
+	Duration   time.Duration
+	Err        error // *ExitError, *ConnError o error de timeout/cancelación
+}
+
+// ExitError indica que el comando se ejecutó en la VM pero terminó con
+// un código de salida distinto de cero
+type ExitError struct {
+	Command    string
+	ExitStatus int
+	Signal     string // señal que terminó el comando, si la hubo
+	Stderr     string
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("comando %q terminó con código %d", e.Command, e.ExitStatus)
+	if e.Signal != "" {
+		msg += " (señal " + e.Signal + ")"
+	}
+	if e.Stderr != "" {
+		msg += ": " + strings.TrimSpace(e.Stderr)
+	}
+	return msg
+}
+
+// ConnError indica un fallo de la conexión SSH: el comando pudo no
+// ejecutarse o no se conoce su resultado
+type ConnError struct {
+	Op  string
+	Err error
+}
+
+func (e *ConnError) Error() string {
+	return fmt.Sprintf("error de conexión SSH (%s): %v", e.Op, e.Err)
+}
+
+func (e *ConnError) Unwrap() error {
+	return e.Err
 }
 
 // isPortAvailable verifica si un puerto está disponible
@@ -27,53 +75,216 @@ func isPortAvailable(port int) bool {
 
 // connectSSH establece la conexión SSH con la VM
 func (vm *QemuVM) connectSSH() error {
-	if vm.ip == "" {
-		return errors.New("IP de la VM no disponible")
+	return vm.connectSSHContext(context.Background())
+}
+
+// connectSSHContext establece la conexión SSH con la VM. Con el reenvío de
+// slirp el puerto acepta conexiones antes de que sshd arranque, por eso el
+// handshake también tiene un tiempo máximo y se aborta al cancelar ctx.
+func (vm *QemuVM) connectSSHContext(ctx context.Context) error {
+	if err := vm.checkSSHAvailable("connectSSH"); err != nil {
+		return err
+	}
+
+	if vm.sshPort == 0 {
+		return errors.New("puerto SSH de la VM no disponible")
+	}
+
+	auth, err := vm.sshAuthMethods()
+	if err != nil {
+		return err
 	}
 
 	// Configuración básica del cliente SSH
 	config := &ssh.ClientConfig{
-		User: "user", // TODO: Hacer configurable
-		Auth: []ssh.AuthMethod{
-			ssh.Password("password"), // TODO: Implementar autenticación por clave
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // TODO: Mejorar seguridad
-		Timeout:         10 * time.Second,
+		User:              vm.sshUser(),
+		Auth:              auth,
+		HostKeyCallback:   vm.hostKeyCallback(),
+		HostKeyAlgorithms: vm.hostKeyAlgorithms(),
+		Timeout:           sshHandshakeTimeout,
 	}
 
 	// Establecer conexión
-	client, err := ssh.Dial("tcp", vm.ip+":22", config)
+	addr := fmt.Sprintf("127.0.0.1:%d", vm.sshPort)
+	dialer := net.Dialer{Timeout: sshHandshakeTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
 		return err
 	}
 
-	vm.sshClient = client
+	deadline := time.Now().Add(sshHandshakeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !stop() || err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	vm.setSSHClient(ssh.NewClient(c, chans, reqs))
 	return nil
 }
 
+// SendCommand ejecuta un comando en la VM por SSH y espera su resultado,
+// con el timeout configurado en CommandTimeout
 func (vm *QemuVM) SendCommand(cmd string) SshCommand {
-	// Implementación de la función SendCommand
-	return SshCommand{
-		Command: cmd,
-		Result:  "",
-		Err:     nil,
+	ctx, cancel := context.WithTimeout(context.Background(), vm.commandTimeout())
+	defer cancel()
+	return vm.SendCommandContext(ctx, cmd)
+}
+
+// commandTimeout devuelve el tiempo máximo configurado por comando
+func (vm *QemuVM) commandTimeout() time.Duration {
+	if vm.config != nil && vm.config.CommandTimeout > 0 {
+		return vm.config.CommandTimeout
+	}
+	return DefaultCommandTimeout
+}
+
+// SendCommandContext ejecuta un comando en la VM por SSH. Si ctx se cancela
+// o vence, el comando remoto se termina y Err envuelve ctx.Err().
+func (vm *QemuVM) SendCommandContext(ctx context.Context, cmd string) SshCommand {
+	if err := vm.checkSSHAvailable("SendCommand"); err != nil {
+		return SshCommand{Command: cmd, ExitStatus: -1, Err: err}
 	}
+	return vm.runCommand(ctx, cmd, nil)
 }
 
-// waitForSSH espera hasta que el servicio SSH esté disponible
-func waitForSSH(host string, port int, timeout int) error {
+// runCommand ejecuta cmd con stdin y recoge su salida en un SshCommand
+func (vm *QemuVM) runCommand(ctx context.Context, cmd string, stdin io.Reader) SshCommand {
+	result := SshCommand{Command: cmd, ExitStatus: -1}
+
+	var stdout, stderr bytes.Buffer
 	start := time.Now()
-	for {
-		conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), 1*time.Second)
-		if err == nil {
-			conn.Close()
-			return nil
+	status, err := vm.runSession(ctx, cmd, stdin, &stdout, &stderr)
+	result.Duration = time.Since(start)
+
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.Result = result.Stdout
+	result.ExitStatus = status
+	result.Err = err
+
+	return result
+}
+
+// newSession abre una sesión SSH. Sin conexión o con la conexión rota
+// reconecta y reintenta una vez: aún no se envió nada, así que es seguro.
+func (vm *QemuVM) newSession(ctx context.Context) (*ssh.Session, error) {
+	client := vm.currentSSH()
+	if client != nil {
+		if session, err := client.NewSession(); err == nil {
+			return session, nil
+		}
+	}
+
+	if err := vm.reconnectSSH(ctx, client); err != nil {
+		return nil, err
+	}
+	client = vm.currentSSH()
+	if client == nil {
+		return nil, &ConnError{Op: "session", Err: errors.New("no hay conexión SSH con la VM")}
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, &ConnError{Op: "session", Err: err}
+	}
+	return session, nil
+}
+
+// runSession ejecuta cmd en una sesión SSH nueva conectando stdin, stdout y
+// stderr. Devuelve el código de salida; el error es *ExitError si el código
+// no es cero, *ConnError si falla el transporte o envuelve ctx.Err() si se
+// cancela, en cuyo caso se envía SIGTERM al proceso remoto y se cierra la sesión.
+func (vm *QemuVM) runSession(ctx context.Context, cmd string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	session, err := vm.newSession(ctx)
+	if err != nil {
+		return -1, err
+	}
+	defer session.Close()
+
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	// Guardar stderr para adjuntarlo al ExitError
+	var stderrBuf bytes.Buffer
+	// Con StdinPipe Wait no espera a que stdin llegue a EOF: un os.Stdin
+	// interactivo bloquearía el final del comando
+	if stdin != nil {
+		w, err := session.StdinPipe()
+		if err != nil {
+			return -1, &ConnError{Op: "stdin", Err: err}
 		}
+		go func() {
+			io.Copy(w, stdin)
+			w.Close()
+		}()
+	}
+	session.Stdout = stdout
+	session.Stderr = io.MultiWriter(stderr, &limitedBuffer{buf: &stderrBuf, max: 4096})
+
+	if err := session.Start(cmd); err != nil {
+		return -1, &ConnError{Op: "start", Err: err}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Terminar el proceso remoto y cerrar la sesión
+		session.Signal(ssh.SIGTERM)
+		session.Close()
+		<-done // esperar a que terminen de copiarse stdout y stderr
+		return -1, fmt.Errorf("comando %q cancelado: %w", cmd, ctx.Err())
+	}
 
-		if time.Since(start) > time.Duration(timeout)*time.Second {
-			return fmt.Errorf("timeout esperando SSH")
+	if err == nil {
+		return 0, nil
+	}
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), &ExitError{
+			Command:    cmd,
+			ExitStatus: exitErr.ExitStatus(),
+			Signal:     exitErr.Signal(),
+			Stderr:     stderrBuf.String(),
 		}
+	}
+
+	// ssh.ExitMissingError y errores de E/S: la conexión se cortó
+	return -1, &ConnError{Op: "wait", Err: err}
+}
+
+// limitedBuffer guarda como máximo max bytes y descarta el resto
+type limitedBuffer struct {
+	buf *bytes.Buffer
+	max int
+}
 
-		time.Sleep(1 * time.Second)
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+		} else {
+			b.buf.Write(p)
+		}
 	}
+	return len(p), nil
 }
@@ -0,0 +1,385 @@
+package goqemu
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeSSHServer simula el servidor SSH del invitado: acepta user/password o
+// las claves autorizadas con authorize y ejecuta los comandos "exec" con
+// sh -c en el host de pruebas
+type fakeSSHServer struct {
+	addr    string
+	hostKey ssh.Signer
+
+	mu         sync.Mutex
+	authorized []ssh.PublicKey
+}
+
+// authorize añade una clave pública a las autorizadas por el servidor
+func (s *fakeSSHServer) authorize(key ssh.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorized = append(s.authorized, key)
+}
+
+func newFakeSSHServer(t *testing.T) *fakeSSHServer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generando clave de host: %v", err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("Error creando clave de host: %v", err)
+	}
+
+	srv := &fakeSSHServer{hostKey: hostKey}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "user" && string(pass) == "password" {
+				return nil, nil
+			}
+			return nil, errors.New("credenciales inválidas")
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+			for _, k := range srv.authorized {
+				if bytes.Equal(k.Marshal(), key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, errors.New("clave no autorizada")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error escuchando: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		ln.Close()
+		wg.Wait()
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				serveFakeSSH(conn, config)
+			}()
+		}
+	}()
+
+	srv.addr = ln.Addr().String()
+	return srv
+}
+
+func serveFakeSSH(conn net.Conn, config *ssh.ServerConfig) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer sconn.Close()
+	go serveFakeGlobal(sconn, reqs)
+
+	for newCh := range chans {
+		switch newCh.ChannelType() {
+		case "session":
+			ch, chReqs, err := newCh.Accept()
+			if err != nil {
+				continue
+			}
+			go serveFakeSession(ch, chReqs)
+
+		case "direct-tcpip":
+			// Túnel local: el "invitado" se conecta al destino pedido
+			var target struct {
+				Host     string
+				Port     uint32
+				OrigHost string
+				OrigPort uint32
+			}
+			ssh.Unmarshal(newCh.ExtraData(), &target)
+			conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+			if err != nil {
+				newCh.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			ch, chReqs, err := newCh.Accept()
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			go ssh.DiscardRequests(chReqs)
+			go pipeFakeChannel(ch, conn)
+
+		default:
+			newCh.Reject(ssh.UnknownChannelType, "tipo de canal no soportado")
+		}
+	}
+}
+
+// serveFakeGlobal atiende tcpip-forward: escucha en el "invitado" y abre un
+// canal forwarded-tcpip por cada conexión
+func serveFakeGlobal(sconn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	listeners := make(map[string]net.Listener)
+	defer func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}()
+
+	for req := range reqs {
+		var fwd struct {
+			Addr string
+			Port uint32
+		}
+		if req.Type != "tcpip-forward" && req.Type != "cancel-tcpip-forward" || ssh.Unmarshal(req.Payload, &fwd) != nil {
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+			continue
+		}
+
+		if req.Type == "cancel-tcpip-forward" {
+			for key, ln := range listeners {
+				if strings.HasSuffix(key, ":"+strconv.Itoa(int(fwd.Port))) {
+					ln.Close()
+					delete(listeners, key)
+				}
+			}
+			req.Reply(true, nil)
+			continue
+		}
+
+		ln, err := net.Listen("tcp", net.JoinHostPort(fwd.Addr, strconv.Itoa(int(fwd.Port))))
+		if err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		port := uint32(ln.Addr().(*net.TCPAddr).Port)
+		listeners[ln.Addr().String()] = ln
+		req.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				payload := ssh.Marshal(struct {
+					Addr       string
+					Port       uint32
+					OriginAddr string
+					OriginPort uint32
+				}{fwd.Addr, port, "127.0.0.1", uint32(conn.RemoteAddr().(*net.TCPAddr).Port)})
+				ch, chReqs, err := sconn.OpenChannel("forwarded-tcpip", payload)
+				if err != nil {
+					conn.Close()
+					continue
+				}
+				go ssh.DiscardRequests(chReqs)
+				go pipeFakeChannel(ch, conn)
+			}
+		}()
+	}
+}
+
+// pipeFakeChannel copia en ambos sentidos entre un canal SSH y una conexión
+func pipeFakeChannel(ch ssh.Channel, conn net.Conn) {
+	defer ch.Close()
+	defer conn.Close()
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(ch, conn); done <- struct{}{} }()
+	go func() { io.Copy(conn, ch); done <- struct{}{} }()
+	<-done
+}
+
+func serveFakeSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+
+	var cmd *exec.Cmd
+	exited := make(chan struct{})
+
+	for req := range reqs {
+		switch req.Type {
+		case "exec", "shell":
+			if cmd != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			// La shell lee los comandos de stdin, sin PTY real
+			cmd = exec.Command("sh")
+			if req.Type == "exec" {
+				cmd = exec.Command("sh", "-c", string(req.Payload[4:]))
+			}
+			cmd.Stdin = ch
+			cmd.Stdout = ch
+			cmd.Stderr = ch.Stderr()
+			cmd.WaitDelay = 100 * time.Millisecond // no esperar a los hijos que heredan la salida
+			if err := cmd.Start(); err != nil {
+				req.Reply(false, nil)
+				return
+			}
+			req.Reply(true, nil)
+
+			go func() {
+				defer close(exited)
+				status := 0
+				if err := cmd.Wait(); err != nil {
+					var exitErr *exec.ExitError
+					if errors.As(err, &exitErr) {
+						status = exitErr.ExitCode()
+					}
+				}
+				payload := make([]byte, 4)
+				binary.BigEndian.PutUint32(payload, uint32(status))
+				ch.SendRequest("exit-status", false, payload)
+				ch.Close()
+			}()
+
+		case "pty-req", "window-change":
+			if req.WantReply {
+				req.Reply(true, nil)
+			}
+
+		case "signal":
+			if cmd != nil && cmd.Process != nil {
+				cmd.Process.Signal(syscall.SIGTERM)
+			}
+			if req.WantReply {
+				req.Reply(true, nil)
+			}
+
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+
+	if cmd != nil {
+		cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// dialFakeSSH devuelve una VM en ejecución conectada al servidor de pruebas
+func dialFakeSSH(t *testing.T, srv *fakeSSHServer) *QemuVM {
+	t.Helper()
+
+	client, err := ssh.Dial("tcp", srv.addr, &ssh.ClientConfig{
+		User:            "user",
+		Auth:            []ssh.AuthMethod{ssh.Password("password")},
+		HostKeyCallback: ssh.FixedHostKey(srv.hostKey.PublicKey()),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Error conectando al servidor SSH de prueba: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return &QemuVM{config: &QemuConfig{}, state: StateRunning, sshClient: client}
+}
+
+func TestSendCommand(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+
+	cmd := vm.SendCommand("echo hola; echo error >&2")
+	if cmd.Err != nil {
+		t.Fatalf("Error ejecutando comando: %v", cmd.Err)
+	}
+	if cmd.Stdout != "hola\n" || cmd.Result != cmd.Stdout {
+		t.Errorf("Stdout esperado %q, obtenido %q (Result %q)", "hola\n", cmd.Stdout, cmd.Result)
+	}
+	if cmd.Stderr != "error\n" {
+		t.Errorf("Stderr esperado %q, obtenido %q", "error\n", cmd.Stderr)
+	}
+	if cmd.ExitStatus != 0 {
+		t.Errorf("Código de salida esperado 0, obtenido %d", cmd.ExitStatus)
+	}
+	if cmd.Duration <= 0 {
+		t.Error("La duración del comando debería ser positiva")
+	}
+}
+
+func TestSendCommandExitError(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+
+	cmd := vm.SendCommand("echo fallo >&2; exit 3")
+	if cmd.ExitStatus != 3 {
+		t.Errorf("Código de salida esperado 3, obtenido %d", cmd.ExitStatus)
+	}
+
+	var exitErr *ExitError
+	if !errors.As(cmd.Err, &exitErr) {
+		t.Fatalf("Se esperaba *ExitError, obtenido %T: %v", cmd.Err, cmd.Err)
+	}
+	if exitErr.ExitStatus != 3 || !strings.Contains(exitErr.Stderr, "fallo") {
+		t.Errorf("ExitError inesperado: %+v", exitErr)
+	}
+
+	var connErr *ConnError
+	if errors.As(cmd.Err, &connErr) {
+		t.Error("Un código de salida distinto de cero no es un error de conexión")
+	}
+}
+
+func TestSendCommandTimeout(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+	vm.config.CommandTimeout = 200 * time.Millisecond
+
+	start := time.Now()
+	cmd := vm.SendCommand("sleep 5")
+	if !errors.Is(cmd.Err, context.DeadlineExceeded) {
+		t.Fatalf("Se esperaba timeout, obtenido: %v", cmd.Err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("SendCommand no respetó el timeout: %v", time.Since(start))
+	}
+
+	// La conexión sigue siendo válida para el siguiente comando
+	if cmd := vm.SendCommand("true"); cmd.Err != nil {
+		t.Errorf("Error tras el timeout: %v", cmd.Err)
+	}
+}
+
+func TestSendCommandConnError(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+	vm.config.ReconnectTimeout = 300 * time.Millisecond
+	vm.sshClient.Close()
+
+	cmd := vm.SendCommand("true")
+	var connErr *ConnError
+	if !errors.As(cmd.Err, &connErr) {
+		t.Fatalf("Se esperaba *ConnError, obtenido %T: %v", cmd.Err, cmd.Err)
+	}
+}
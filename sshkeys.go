@@ -0,0 +1,197 @@
+package goqemu
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultSSHUser es el usuario SSH por defecto. La imagen por defecto no
+// trae cloud-init y solo recibe la clave por la credencial SMBIOS, que la
+// instala para root. Con otro usuario la imagen debe incluir cloud-init.
+const DefaultSSHUser = "root"
+
+// sshKeyPath devuelve la ruta de la clave privada de la VM: la configurada
+// en SSHKeyPath o id_ed25519 en el directorio de trabajo
+func (vm *QemuVM) sshKeyPath() string {
+	if vm.config != nil && vm.config.SSHKeyPath != "" {
+		return vm.config.SSHKeyPath
+	}
+	return filepath.Join(vm.workDir, "id_ed25519")
+}
+
+// sshUser devuelve el usuario SSH configurado
+func (vm *QemuVM) sshUser() string {
+	if vm.config != nil && vm.config.SSHUser != "" {
+		return vm.config.SSHUser
+	}
+	return DefaultSSHUser
+}
+
+// loadSSHKey carga la clave privada de la VM. Si no hay clave configurada
+// y aún no existe, genera un par ed25519 nuevo en el directorio de trabajo.
+func (vm *QemuVM) loadSSHKey() (ssh.Signer, error) {
+	if vm.sshSigner != nil {
+		return vm.sshSigner, nil
+	}
+
+	path := vm.sshKeyPath()
+	configured := vm.config != nil && vm.config.SSHKeyPath != ""
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !configured {
+		data, err = generateSSHKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo clave SSH %s: %v", path, err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("clave SSH inválida %s: %v", path, err)
+	}
+
+	vm.sshSigner = signer
+	return signer, nil
+}
+
+// generateSSHKey crea un par de claves ed25519: la privada en path con
+// permisos 0600 y la pública en path.pub. Devuelve la clave privada en PEM.
+func generateSSHKey(path string) ([]byte, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "goqemu")
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(block)
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path+".pub", ssh.MarshalAuthorizedKey(sshPub), 0644); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// SSHPublicKey devuelve la clave pública de la VM en formato authorized_keys
+func (vm *QemuVM) SSHPublicKey() (string, error) {
+	signer, err := vm.loadSSHKey()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))), nil
+}
+
+// seedDir devuelve el directorio con los datos NoCloud de cloud-init
+func (vm *QemuVM) seedDir() string {
+	return filepath.Join(vm.workDir, "cidata")
+}
+
+// writeSeed escribe los datos NoCloud que crean el usuario SSH con la clave
+// pública de la VM en el primer arranque. El instance-id es el id de la VM,
+// que tiene su propio disco: cloud-init genera las claves de host una sola
+// vez y una VM con nombre las conserva entre ejecuciones.
+func (vm *QemuVM) writeSeed(authorizedKey string) error {
+	dir := vm.seedDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	metaData := fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", vm.id, vm.id)
+
+	password := ""
+	if vm.config != nil {
+		password = vm.config.SSHPassword
+	}
+
+	var b strings.Builder
+	b.WriteString("#cloud-config\n")
+	user := vm.sshUser()
+	if user == "root" {
+		// cloud-init deshabilita el acceso de root por defecto
+		b.WriteString("disable_root: false\n")
+	}
+	b.WriteString("users:\n")
+	b.WriteString("  - default\n")
+	fmt.Fprintf(&b, "  - name: %s\n", strconv.Quote(user))
+	if user != "root" {
+		b.WriteString("    sudo: \"ALL=(ALL) NOPASSWD:ALL\"\n")
+		b.WriteString("    shell: /bin/bash\n")
+	}
+	if password != "" {
+		b.WriteString("    lock_passwd: false\n")
+		fmt.Fprintf(&b, "    plain_text_passwd: %s\n", strconv.Quote(password))
+	}
+	b.WriteString("    ssh_authorized_keys:\n")
+	fmt.Fprintf(&b, "      - %s\n", strconv.Quote(authorizedKey))
+	fmt.Fprintf(&b, "ssh_pwauth: %t\n", password != "")
+	if vm.config != nil && vm.config.GuestAgent {
+		// Las imágenes cloud no suelen traer el agente instalado
+		b.WriteString("packages:\n")
+		b.WriteString("  - qemu-guest-agent\n")
+		b.WriteString("runcmd:\n")
+		b.WriteString("  - [systemctl, start, qemu-guest-agent]\n")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "meta-data"), []byte(metaData), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "user-data"), []byte(b.String()), 0644)
+}
+
+// provisionArgs prepara la clave SSH y devuelve los argumentos que la
+// entregan al invitado: un disco FAT con etiqueta cidata para cloud-init y
+// una credencial SMBIOS de systemd para las imágenes sin cloud-init, que
+// instala la clave en /root/.ssh/authorized_keys
+func (vm *QemuVM) provisionArgs() ([]string, error) {
+	authorizedKey, err := vm.SSHPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := vm.writeSeed(authorizedKey); err != nil {
+		return nil, fmt.Errorf("error escribiendo datos de cloud-init: %v", err)
+	}
+
+	credential := base64.StdEncoding.EncodeToString([]byte(authorizedKey + "\n"))
+
+	return []string{
+		"-drive", fmt.Sprintf("if=virtio,format=raw,readonly=on,file.driver=vvfat,file.dir=%s,file.label=cidata", qemuOptValue(vm.seedDir())),
+		"-smbios", "type=11,value=io.systemd.credential.binary:ssh.authorized_keys.root=" + credential,
+	}, nil
+}
+
+// sshAuthMethods devuelve la autenticación por clave y, si se configuró,
+// por contraseña
+func (vm *QemuVM) sshAuthMethods() ([]ssh.AuthMethod, error) {
+	signer, err := vm.loadSSHKey()
+	if err != nil {
+		return nil, err
+	}
+
+	methods := []ssh.AuthMethod{ssh.PublicKeys(signer)}
+	if vm.config != nil && vm.config.SSHPassword != "" {
+		methods = append(methods, ssh.Password(vm.config.SSHPassword))
+	}
+	return methods, nil
+}
@@ -0,0 +1,94 @@
+package goqemu
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSSHKey(t *testing.T) {
+	vm := &QemuVM{config: &QemuConfig{}, workDir: t.TempDir(), id: "vm-test"}
+
+	pub, err := vm.SSHPublicKey()
+	if err != nil {
+		t.Fatalf("Error generando clave: %v", err)
+	}
+	if !strings.HasPrefix(pub, "ssh-ed25519 ") {
+		t.Errorf("Clave pública inesperada: %s", pub)
+	}
+
+	info, err := os.Stat(filepath.Join(vm.workDir, "id_ed25519"))
+	if err != nil {
+		t.Fatalf("No se guardó la clave privada: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Permisos de la clave privada %o, se esperaba 0600", perm)
+	}
+
+	// Otra VM con el mismo directorio reutiliza la clave
+	other := &QemuVM{config: &QemuConfig{}, workDir: vm.workDir}
+	if pub2, _ := other.SSHPublicKey(); pub2 != pub {
+		t.Error("La clave existente no se reutilizó")
+	}
+
+	// La clave pública llega al invitado por cloud-init y SMBIOS
+	args, err := vm.provisionArgs()
+	if err != nil {
+		t.Fatalf("Error preparando aprovisionamiento: %v", err)
+	}
+	cmdline := strings.Join(args, " ")
+	for _, want := range []string{"file.label=cidata", "io.systemd.credential.binary:ssh.authorized_keys.root="} {
+		if !strings.Contains(cmdline, want) {
+			t.Errorf("Los argumentos no contienen %q: %s", want, cmdline)
+		}
+	}
+	userData, err := os.ReadFile(filepath.Join(vm.seedDir(), "user-data"))
+	if err != nil {
+		t.Fatalf("No se escribió user-data: %v", err)
+	}
+	if !strings.Contains(string(userData), pub) || !strings.Contains(string(userData), `name: "root"`) ||
+		!strings.Contains(string(userData), "disable_root: false") {
+		t.Errorf("user-data no contiene el usuario y la clave:\n%s", userData)
+	}
+
+	// Con otro usuario la imagen debe traer cloud-init, que lo crea con sudo
+	vm.config.SSHUser = "debian"
+	if _, err := vm.provisionArgs(); err != nil {
+		t.Fatalf("Error preparando aprovisionamiento: %v", err)
+	}
+	userData, _ = os.ReadFile(filepath.Join(vm.seedDir(), "user-data"))
+	if !strings.Contains(string(userData), `name: "debian"`) || !strings.Contains(string(userData), "NOPASSWD") ||
+		strings.Contains(string(userData), "disable_root") {
+		t.Errorf("user-data inesperado para un usuario sin privilegios:\n%s", userData)
+	}
+}
+
+func TestConnectSSHWithKey(t *testing.T) {
+	srv := newFakeSSHServer(t)
+	_, portStr, _ := net.SplitHostPort(srv.addr)
+	port, _ := strconv.Atoi(portStr)
+
+	vm := &QemuVM{config: &QemuConfig{}, state: StateRunning, workDir: t.TempDir(), sshPort: port}
+	signer, err := vm.loadSSHKey()
+	if err != nil {
+		t.Fatalf("Error generando clave: %v", err)
+	}
+
+	// Sin la clave autorizada ni contraseña la conexión falla
+	if err := vm.connectSSH(); err == nil {
+		t.Fatal("La conexión debería fallar con una clave no autorizada")
+	}
+
+	srv.authorize(signer.PublicKey())
+	if err := vm.connectSSH(); err != nil {
+		t.Fatalf("Error conectando con la clave: %v", err)
+	}
+	defer vm.sshClient.Close()
+
+	if cmd := vm.SendCommand("echo ok"); cmd.Stdout != "ok\n" {
+		t.Errorf("Salida inesperada: %q, %v", cmd.Stdout, cmd.Err)
+	}
+}
@@ -0,0 +1,89 @@
+package goqemu
+
+import (
+	"fmt"
+)
+
+// VMState es el estado del ciclo de vida de una VM
+type VMState string
+
+const (
+	StateCreated      VMState = "created"       // creada con NewQemuVM, nunca iniciada
+	StateStarting     VMState = "starting"      // QEMU lanzado, esperando SSH
+	StateRunning      VMState = "running"       // en ejecución
+	StatePaused       VMState = "paused"        // CPUs detenidas
+	StateShuttingDown VMState = "shutting-down" // deteniéndose
+	StateStopped      VMState = "stopped"       // detenida, se puede volver a iniciar
+	StateCrashed      VMState = "crashed"       // QEMU terminó inesperadamente
+)
+
+// vmTransitions define las transiciones válidas desde cada estado
+var vmTransitions = map[VMState][]VMState{
+	StateCreated:      {StateStarting},
+	StateStarting:     {StateRunning, StateStopped, StateCrashed, StateShuttingDown},
+	StateRunning:      {StatePaused, StateShuttingDown, StateCrashed},
+	StatePaused:       {StateRunning, StateShuttingDown, StateCrashed},
+	StateShuttingDown: {StateStopped, StateCrashed},
+	StateStopped:      {StateStarting},
+	StateCrashed:      {StateStarting, StateShuttingDown},
+}
+
+// StateError indica que una operación no está permitida en el estado actual de la VM
+type StateError struct {
+	Op    string  // operación solicitada: Start, Stop, Pause...
+	State VMState // estado de la VM al solicitarla
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("no se puede ejecutar %s: la VM está en estado %s", e.Op, e.State)
+}
+
+// canTransition indica si se permite pasar del estado from al estado to
+func canTransition(from, to VMState) bool {
+	for _, s := range vmTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Status devuelve el estado actual de la VM
+func (vm *QemuVM) Status() VMState {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.state
+}
+
+// transition cambia el estado de la VM validando la transición.
+// op identifica la operación en el error devuelto.
+func (vm *QemuVM) transition(op string, to VMState) (VMState, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	from := vm.state
+	if !canTransition(from, to) {
+		return from, &StateError{Op: op, State: from}
+	}
+	vm.state = to
+	return from, nil
+}
+
+// setState fuerza el estado de la VM sin validar, usado para revertir
+// una transición cuando la operación falla
+func (vm *QemuVM) setState(state VMState) {
+	vm.mu.Lock()
+	vm.state = state
+	vm.mu.Unlock()
+}
+
+// requireState devuelve un error si la VM no está en alguno de los estados indicados
+func (vm *QemuVM) requireState(op string, states ...VMState) error {
+	current := vm.Status()
+	for _, s := range states {
+		if current == s {
+			return nil
+		}
+	}
+	return &StateError{Op: op, State: current}
+}
@@ -0,0 +1,86 @@
+package goqemu
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestVMStateTransitions(t *testing.T) {
+	tests := []struct {
+		from, to VMState
+		valid    bool
+	}{
+		{StateCreated, StateStarting, true},
+		{StateCreated, StateShuttingDown, false},
+		{StateRunning, StatePaused, true},
+		{StatePaused, StateRunning, true},
+		{StateStopped, StateStarting, true},
+		{StateStopped, StateShuttingDown, false},
+		{StateCrashed, StateStarting, true},
+		{StateShuttingDown, StateRunning, false},
+	}
+
+	for _, tt := range tests {
+		if got := canTransition(tt.from, tt.to); got != tt.valid {
+			t.Errorf("canTransition(%s, %s) = %v, esperado %v", tt.from, tt.to, got, tt.valid)
+		}
+	}
+}
+
+func TestStopTwice(t *testing.T) {
+	vm := &QemuVM{config: &QemuConfig{}, state: StateRunning, workDir: t.TempDir()}
+	vm.ctx, vm.cancel = context.WithCancel(context.Background())
+
+	if err := vm.Stop(); err != nil {
+		t.Fatalf("Error en el primer Stop: %v", err)
+	}
+	if vm.Status() != StateStopped {
+		t.Errorf("Estado esperado %s, obtenido %s", StateStopped, vm.Status())
+	}
+
+	err := vm.Stop()
+	var stateErr *StateError
+	if !errors.As(err, &stateErr) || stateErr.State != StateStopped {
+		t.Fatalf("Se esperaba StateError en el segundo Stop, obtenido: %v", err)
+	}
+
+	// La configuración se conserva para poder reiniciar la VM
+	if _, err := vm.ListSnapshots(); err != nil && err.Error() == "VM no configurada" {
+		t.Errorf("La VM perdió su configuración tras Stop")
+	}
+}
+
+func TestStopRemovesUnnamedVMDir(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	runner := NewFakeRunner()
+	runner.OnStart = func(p *FakeProcess) { p.Exit(1, "") }
+
+	for _, name := range []string{"", "web"} {
+		vm := &QemuVM{config: &QemuConfig{Name: name, DiskSize: 10}, state: StateRunning, runner: runner}
+		vm.workDir, _ = createVMDir("vm-" + name)
+		vm.ctx, vm.cancel = context.WithCancel(context.Background())
+		os.WriteFile(getDiskPath(vm.workDir), nil, 0644)
+
+		if err := vm.Stop(); err != nil {
+			t.Fatalf("Error en Stop: %v", err)
+		}
+		_, err := os.Stat(vm.workDir)
+		if removed := os.IsNotExist(err); removed != (name == "") {
+			t.Errorf("VM %q: directorio borrado = %v", name, removed)
+		}
+	}
+
+	// Al volver a arrancar, la VM sin nombre recrea su disco
+	vm := &QemuVM{config: &QemuConfig{DiskSize: 10}, state: StateStopped, runner: runner}
+	vm.workDir = getVMDir("vm-")
+	vm.ctx, vm.cancel = context.WithCancel(context.Background())
+	vm.Start()
+
+	calls := runner.CallsTo("qemu-img")
+	if len(calls) != 1 || calls[0].Args[len(calls[0].Args)-1] != "10G" {
+		t.Errorf("Se esperaba recrear el disco de 10G, llamadas: %v", calls)
+	}
+}
@@ -0,0 +1,23 @@
+package goqemu
+
+import (
+	"context"
+	"io"
+)
+
+// RunStream ejecuta cmd en la VM escribiendo su salida en stdout y stderr a
+// medida que se produce. Si ctx se cancela se envía SIGTERM al proceso
+// remoto, se cierra la sesión y el error envuelve ctx.Err(). Devuelve el
+// código de salida; si no es cero el error es un *ExitError.
+func (vm *QemuVM) RunStream(ctx context.Context, cmd string, stdout, stderr io.Writer) (int, error) {
+	return vm.RunStreamInput(ctx, cmd, nil, stdout, stderr)
+}
+
+// RunStreamInput es RunStream enviando stdin al proceso remoto. Al llegar
+// stdin a EOF se cierra la entrada del comando.
+func (vm *QemuVM) RunStreamInput(ctx context.Context, cmd string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	if err := vm.checkSSHAvailable("RunStream"); err != nil {
+		return -1, err
+	}
+	return vm.runSession(ctx, cmd, stdin, stdout, stderr)
+}
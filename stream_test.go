@@ -0,0 +1,84 @@
+package goqemu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// timedWriter registra cuándo llega cada escritura
+type timedWriter struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writes []time.Time
+}
+
+func (w *timedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes = append(w.writes, time.Now())
+	return w.buf.Write(p)
+}
+
+func TestRunStream(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+
+	var stdout timedWriter
+	var stderr bytes.Buffer
+	start := time.Now()
+	status, err := vm.RunStream(context.Background(), "echo uno; sleep 0.5; echo dos; echo aviso >&2", &stdout, &stderr)
+	if err != nil || status != 0 {
+		t.Fatalf("Error en RunStream: %d, %v", status, err)
+	}
+
+	if stdout.buf.String() != "uno\ndos\n" || stderr.String() != "aviso\n" {
+		t.Errorf("Salida inesperada: %q / %q", stdout.buf.String(), stderr.String())
+	}
+	// La primera línea llega antes de que termine el comando
+	if len(stdout.writes) == 0 || stdout.writes[0].Sub(start) > 400*time.Millisecond {
+		t.Errorf("La salida no se transmitió en vivo: %v", stdout.writes)
+	}
+}
+
+func TestRunStreamInput(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+
+	var stdout bytes.Buffer
+	status, err := vm.RunStreamInput(context.Background(), "tr a-z A-Z", strings.NewReader("hola\n"), &stdout, nil)
+	if err != nil || status != 0 {
+		t.Fatalf("Error en RunStreamInput: %d, %v", status, err)
+	}
+	if stdout.String() != "HOLA\n" {
+		t.Errorf("Salida inesperada: %q", stdout.String())
+	}
+
+	status, err = vm.RunStream(context.Background(), "exit 7", nil, nil)
+	var exitErr *ExitError
+	if status != 7 || !errors.As(err, &exitErr) {
+		t.Errorf("Se esperaba código 7 y *ExitError, obtenido %d, %v", status, err)
+	}
+}
+
+func TestRunStreamCancel(t *testing.T) {
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	var stdout bytes.Buffer
+	start := time.Now()
+	_, err := vm.RunStream(ctx, "echo empezando; sleep 5", &stdout, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Se esperaba context.Canceled, obtenido: %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("RunStream no se canceló a tiempo: %v", time.Since(start))
+	}
+	if stdout.String() != "empezando\n" {
+		t.Errorf("Salida previa a la cancelación: %q", stdout.String())
+	}
+}
@@ -0,0 +1,150 @@
+package goqemu
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const crashLogLines = 20 // Líneas del log de QEMU adjuntas al error de caída
+
+// ExitStatus describe cómo terminó el proceso QEMU
+type ExitStatus struct {
+	Code    int    // código de salida, -1 si terminó por una señal
+	Reason  string // motivo: guest-shutdown, host-qmp-quit, signal: killed...
+	Crashed bool   // true si QEMU terminó sin que se llamara a Stop
+	Err     error  // *CrashError si Crashed
+}
+
+// CrashError se produce cuando QEMU termina inesperadamente. Incluye las
+// últimas líneas del log de QEMU para explicar la caída.
+type CrashError struct {
+	Code    int
+	Reason  string
+	LogPath string
+	LogTail []string
+}
+
+func (e *CrashError) Error() string {
+	msg := fmt.Sprintf("QEMU terminó inesperadamente (código %d, %s)", e.Code, e.Reason)
+	if len(e.LogTail) > 0 {
+		msg += fmt.Sprintf("\núltimas líneas de %s:\n%s", e.LogPath, strings.Join(e.LogTail, "\n"))
+	}
+	return msg
+}
+
+// logFilePath devuelve la ruta del log de stdout/stderr de QEMU
+func (vm *QemuVM) logFilePath() string {
+	return filepath.Join(vm.workDir, "qemu.log")
+}
+
+// LogPath devuelve la ruta del archivo donde se guarda la salida de QEMU
+func (vm *QemuVM) LogPath() string {
+	return vm.logFilePath()
+}
+
+// Done devuelve un canal que se cierra cuando termina el proceso QEMU
+// de la ejecución actual. Devuelve nil si la VM nunca se inició.
+func (vm *QemuVM) Done() <-chan struct{} {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.exited
+}
+
+// Wait bloquea hasta que termina el proceso QEMU y devuelve su estado de
+// salida. Si QEMU terminó inesperadamente el error es un *CrashError.
+func (vm *QemuVM) Wait() (ExitStatus, error) {
+	done := vm.Done()
+	if done == nil {
+		return ExitStatus{}, &StateError{Op: "Wait", State: vm.Status()}
+	}
+	<-done
+
+	vm.mu.Lock()
+	status := vm.exitStatus
+	vm.mu.Unlock()
+
+	return status, status.Err
+}
+
+// supervise recoge el proceso QEMU cuando termina y registra su estado de
+// salida. Si QEMU terminó sin que se llamara a Stop, un apagado informado
+// por QEMU (SHUTDOWN) con código 0 detiene la VM; cualquier otra salida la
+// marca como caída.
+func (vm *QemuVM) supervise(proc Process, logFile io.Closer, exited chan struct{}) {
+	exit, _ := proc.Wait()
+	if logFile != nil {
+		logFile.Close()
+	}
+
+	status := ExitStatus{Code: exit.Code, Reason: exit.Reason}
+
+	vm.mu.Lock()
+	if vm.shutdownReason != "" {
+		status.Reason = vm.shutdownReason
+	}
+
+	stopped := false
+	switch vm.state {
+	case StateRunning, StatePaused:
+		// El invitado se apagó o QEMU recibió quit por el monitor
+		if vm.shutdownReason != "" && status.Code == 0 {
+			vm.state = StateShuttingDown
+			stopped = true
+			break
+		}
+		fallthrough
+	case StateStarting:
+		status.Crashed = true
+		status.Err = &CrashError{
+			Code:    status.Code,
+			Reason:  status.Reason,
+			LogPath: vm.logFilePath(),
+			LogTail: tailFile(vm.logFilePath(), crashLogLines),
+		}
+		vm.state = StateCrashed
+	}
+
+	vm.exitStatus = status
+	vm.mu.Unlock()
+
+	if stopped {
+		vm.finishStop()
+	}
+
+	close(exited)
+}
+
+// handleQMPEvent registra el motivo del apagado que informa QEMU
+func (vm *QemuVM) handleQMPEvent(event QMPEvent) {
+	if event.Name != "SHUTDOWN" {
+		return
+	}
+	if reason, ok := event.Data["reason"].(string); ok {
+		vm.mu.Lock()
+		vm.shutdownReason = reason
+		vm.mu.Unlock()
+	}
+}
+
+// tailFile devuelve las últimas n líneas de un archivo
+func tailFile(path string, n int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines
+}
@@ -0,0 +1,200 @@
+package goqemu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSuperviseCrash(t *testing.T) {
+	vm := &QemuVM{config: &QemuConfig{}, state: StateRunning, workDir: t.TempDir()}
+
+	logFile, err := os.Create(vm.logFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Proceso real para comprobar el código de salida de ExecRunner
+	proc, err := ExecRunner{}.Start("sh", []string{"-c", "echo 'qemu: fallo grave' >&2; exit 3"}, logFile, logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exited := vm.setProcess(proc)
+	go vm.supervise(proc, logFile, exited)
+
+	status, err := vm.Wait()
+	var crash *CrashError
+	if !errors.As(err, &crash) {
+		t.Fatalf("Se esperaba CrashError, obtenido: %v", err)
+	}
+	if status.Code != 3 || !status.Crashed {
+		t.Errorf("Estado de salida incorrecto: %+v", status)
+	}
+	if !strings.Contains(strings.Join(crash.LogTail, "\n"), "fallo grave") {
+		t.Errorf("El error no incluye el log de QEMU: %v", crash)
+	}
+	if vm.Status() != StateCrashed {
+		t.Errorf("Estado esperado %s, obtenido %s", StateCrashed, vm.Status())
+	}
+}
+
+func TestSuperviseStop(t *testing.T) {
+	vm := &QemuVM{config: &QemuConfig{}, state: StateShuttingDown, workDir: t.TempDir()}
+
+	proc, _ := NewFakeRunner().Start("qemu-system-x86_64", nil, nil, nil)
+	exited := vm.setProcess(proc)
+	vm.handleQMPEvent(QMPEvent{Name: "SHUTDOWN", Data: map[string]any{"reason": "guest-shutdown"}})
+	go vm.supervise(proc, nil, exited)
+
+	proc.(*FakeProcess).Exit(0, "")
+
+	status, err := vm.Wait()
+	if err != nil {
+		t.Fatalf("Una parada ordenada no debería devolver error: %v", err)
+	}
+	if status.Crashed || status.Reason != "guest-shutdown" {
+		t.Errorf("Estado de salida incorrecto: %+v", status)
+	}
+}
+
+func TestSuperviseGuestShutdown(t *testing.T) {
+	vm := &QemuVM{config: &QemuConfig{Name: "web"}, state: StateRunning, workDir: t.TempDir()}
+	vm.ctx, vm.cancel = context.WithCancel(context.Background())
+	vm.startQueue()
+
+	proc, _ := NewFakeRunner().Start("qemu-system-x86_64", nil, nil, nil)
+	exited := vm.setProcess(proc)
+	vm.handleQMPEvent(QMPEvent{Name: "SHUTDOWN", Data: map[string]any{"reason": "guest-shutdown"}})
+	go vm.supervise(proc, nil, exited)
+
+	// El invitado se apaga por su cuenta: es una parada, no una caída
+	proc.(*FakeProcess).Exit(0, "")
+
+	status, err := vm.Wait()
+	if err != nil {
+		t.Fatalf("Un apagado del invitado no debería devolver error: %v", err)
+	}
+	if status.Crashed || status.Reason != "guest-shutdown" {
+		t.Errorf("Estado de salida incorrecto: %+v", status)
+	}
+	if vm.Status() != StateStopped {
+		t.Errorf("Estado esperado %s, obtenido %s", StateStopped, vm.Status())
+	}
+	if vm.ctx.Err() == nil || vm.queue != nil {
+		t.Error("Los recursos de la ejecución deberían liberarse")
+	}
+}
+
+func TestStartCrashWithFakeRunner(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	runner := NewFakeRunner()
+	runner.OnStart = func(p *FakeProcess) {
+		fmt.Fprintln(p.Stderr, "qemu-system-x86_64: -hda disk.qcow2: Could not open disk.qcow2")
+		p.Exit(1, "")
+	}
+
+	vm := &QemuVM{
+		config:      &QemuConfig{Display: DisplayNone},
+		state:       StateCreated,
+		runner:      runner,
+		defaultArgs: []string{"-m", "4G", "-smp", "2"},
+		ip:          "10.0.2.15",
+	}
+	vm.workDir, _ = createVMDir("test")
+	vm.ctx, vm.cancel = context.WithCancel(context.Background())
+
+	err := vm.Start()
+	var crash *CrashError
+	if !errors.As(err, &crash) {
+		t.Fatalf("Se esperaba CrashError, obtenido: %v", err)
+	}
+	if !strings.Contains(crash.Error(), "Could not open disk.qcow2") {
+		t.Errorf("El error no incluye el log de QEMU: %v", crash)
+	}
+	if vm.Status() != StateCrashed {
+		t.Errorf("Estado esperado %s, obtenido %s", StateCrashed, vm.Status())
+	}
+
+	calls := runner.CallsTo("qemu-system-x86_64")
+	if len(calls) != 1 {
+		t.Fatalf("Se esperaba una llamada a QEMU, obtenidas: %v", calls)
+	}
+	cmdline := calls[0].CommandLine()
+	for _, want := range []string{"-m 4G -smp 2", "hostfwd=tcp:127.0.0.1:", "-qmp unix:", "-pidfile"} {
+		if !strings.Contains(cmdline, want) {
+			t.Errorf("La línea de comando no contiene %q: %s", want, cmdline)
+		}
+	}
+}
+
+func TestStartAfterCrashReleasesPreviousRun(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	vm := &QemuVM{
+		config:      &QemuConfig{Display: DisplayNone},
+		state:       StateCrashed,
+		defaultArgs: []string{"-m", "4G", "-smp", "2"},
+	}
+	vm.workDir, _ = createVMDir("test")
+	vm.ctx, vm.cancel = context.WithCancel(context.Background())
+
+	// Cola de la ejecución que terminó con la caída
+	vm.startQueue()
+	old := vm.queue
+
+	runner := NewFakeRunner()
+	queueStopped := false
+	runner.OnStart = func(p *FakeProcess) {
+		queueStopped = old.ctx.Err() != nil && vm.queue == nil
+		p.Exit(1, "")
+	}
+	vm.runner = runner
+
+	var crash *CrashError
+	if err := vm.Start(); !errors.As(err, &crash) {
+		t.Fatalf("Se esperaba CrashError, obtenido: %v", err)
+	}
+	if !queueStopped {
+		t.Error("La cola de la ejecución anterior debería detenerse antes de relanzar QEMU")
+	}
+}
+
+func TestOpenWindowStartsLikeStart(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	runner := NewFakeRunner()
+	runner.OnStart = func(p *FakeProcess) { p.Exit(1, "") }
+
+	vm := &QemuVM{
+		config:      &QemuConfig{Display: DisplayVNC, VNCPort: 5901},
+		state:       StateStopped,
+		runner:      runner,
+		defaultArgs: []string{"-m", "4G", "-smp", "2"},
+	}
+	vm.workDir, _ = createVMDir("test")
+	vm.ctx, vm.cancel = context.WithCancel(context.Background())
+
+	var crash *CrashError
+	if err := vm.OpenWindow(); !errors.As(err, &crash) {
+		t.Fatalf("Se esperaba CrashError, obtenido: %v", err)
+	}
+
+	calls := runner.CallsTo("qemu-system-x86_64")
+	if len(calls) != 1 {
+		t.Fatalf("Se esperaba una llamada a QEMU, obtenidas: %v", calls)
+	}
+	cmdline := calls[0].CommandLine()
+	for _, want := range []string{"-display gtk", "hostfwd=tcp:127.0.0.1:", "-qmp unix:", "-chardev socket", "-pidfile"} {
+		if !strings.Contains(cmdline, want) {
+			t.Errorf("La línea de comando no contiene %q: %s", want, cmdline)
+		}
+	}
+	if strings.Contains(cmdline, "-vnc") {
+		t.Errorf("La ventana no debería usar VNC: %s", cmdline)
+	}
+}
@@ -0,0 +1,65 @@
+//go:build !windows
+
+package goqemu
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// watchWindowSize llama a resize con el nuevo tamaño de la terminal fd en
+// cada SIGWINCH. La función devuelta deja de vigilar.
+func watchWindowSize(fd int, resize func(width, height int)) func() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGWINCH)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-sig:
+				if w, h, err := term.GetSize(fd); err == nil {
+					resize(w, h)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
+
+// cancelableReader devuelve un lector de r que deja de leer al llamar a
+// stop, sin consumir más datos de r. Si r es un *os.File se lee de un
+// duplicado no bloqueante que stop cierra; ok es false si no es posible y
+// el lector devuelto es r.
+func cancelableReader(r io.Reader) (reader io.Reader, stop func(), ok bool) {
+	f, isFile := r.(*os.File)
+	if !isFile {
+		return r, func() {}, false
+	}
+	orig := int(f.Fd())
+	fd, err := syscall.Dup(orig)
+	if err != nil {
+		return r, func() {}, false
+	}
+	// O_NONBLOCK es común a ambos descriptores: stop lo restablece
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return r, func() {}, false
+	}
+
+	dup := os.NewFile(uintptr(fd), f.Name())
+	return dup, func() {
+		dup.Close()
+		syscall.SetNonblock(orig, false)
+	}, true
+}
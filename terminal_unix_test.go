@@ -0,0 +1,53 @@
+//go:build !windows
+
+package goqemu
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCancelableReader(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	reader, stop, ok := cancelableReader(r)
+	if !ok {
+		t.Fatal("Un pipe debería poder leerse de forma cancelable")
+	}
+
+	w.Write([]byte("a"))
+	buf := make([]byte, 1)
+	if _, err := io.ReadFull(reader, buf); err != nil || buf[0] != 'a' {
+		t.Fatalf("Lectura inesperada: %q, %v", buf, err)
+	}
+
+	// Una lectura en curso termina al detener el lector
+	done := make(chan error, 1)
+	go func() {
+		_, err := reader.Read(buf)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Se esperaba error tras detener el lector")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("La lectura no terminó al detener el lector")
+	}
+
+	// La siguiente entrada queda para el llamador
+	w.Write([]byte("b"))
+	if _, err := io.ReadFull(r, buf); err != nil || buf[0] != 'b' {
+		t.Errorf("La entrada posterior se perdió: %q, %v", buf, err)
+	}
+}
@@ -0,0 +1,43 @@
+//go:build windows
+
+package goqemu
+
+import (
+	"io"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// watchWindowSize consulta el tamaño de la terminal fd cada medio segundo,
+// Windows no tiene SIGWINCH, y llama a resize si cambió. La función
+// devuelta deja de vigilar.
+func watchWindowSize(fd int, resize func(width, height int)) func() {
+	done := make(chan struct{})
+	width, height, _ := term.GetSize(fd)
+
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w, h, err := term.GetSize(fd)
+				if err == nil && (w != width || h != height) {
+					width, height = w, h
+					resize(w, h)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// cancelableReader devuelve r sin cambios: en Windows la lectura de la
+// consola no se puede interrumpir
+func cancelableReader(r io.Reader) (reader io.Reader, stop func(), ok bool) {
+	return r, func() {}, false
+}
@@ -0,0 +1,317 @@
+package goqemu
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CopyTo copia un archivo o un directorio del host a remotePath en la VM,
+// como cp -r. Conserva permisos y fechas de modificación y transmite el
+// contenido como un tar sobre una sesión SSH, sin cargarlo en memoria.
+func (vm *QemuVM) CopyTo(localPath, remotePath string) error {
+	return vm.CopyToContext(context.Background(), localPath, remotePath)
+}
+
+// CopyToContext es CopyTo con cancelación por contexto
+func (vm *QemuVM) CopyToContext(ctx context.Context, localPath, remotePath string) error {
+	if err := vm.checkSSHAvailable("CopyTo"); err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(localPath); err != nil {
+		return fmt.Errorf("error copiando a la VM: %v", err)
+	}
+
+	remotePath = path.Clean(remotePath)
+	dir, base := path.Split(remotePath)
+	if dir == "" {
+		dir = "."
+	}
+
+	pr, pw := io.Pipe()
+	archived := make(chan error, 1)
+	go func() {
+		err := writeTar(pw, localPath, base)
+		pw.CloseWithError(err)
+		archived <- err
+	}()
+
+	cmd := fmt.Sprintf("mkdir -p %s && tar -xpf - -C %s", shellQuote(dir), shellQuote(dir))
+	_, err := vm.runSession(ctx, cmd, pr, io.Discard, io.Discard)
+	pr.Close()
+
+	// Un error leyendo los archivos locales explica mejor el fallo
+	if archiveErr := <-archived; archiveErr != nil && archiveErr != io.ErrClosedPipe {
+		err = archiveErr
+	}
+	if err != nil {
+		return fmt.Errorf("error copiando %s a %s en la VM: %w", localPath, remotePath, err)
+	}
+	return nil
+}
+
+// CopyFrom copia un archivo o un directorio remotePath de la VM a localPath
+// en el host, como cp -r, conservando permisos y fechas de modificación
+func (vm *QemuVM) CopyFrom(remotePath, localPath string) error {
+	return vm.CopyFromContext(context.Background(), remotePath, localPath)
+}
+
+// CopyFromContext es CopyFrom con cancelación por contexto
+func (vm *QemuVM) CopyFromContext(ctx context.Context, remotePath, localPath string) error {
+	if err := vm.checkSSHAvailable("CopyFrom"); err != nil {
+		return err
+	}
+
+	remotePath = path.Clean(remotePath)
+	dir, base := path.Split(remotePath)
+	if dir == "" {
+		dir = "."
+	}
+
+	pr, pw := io.Pipe()
+	extracted := make(chan error, 1)
+	go func() {
+		err := readTar(pr, base, localPath)
+		// Consumir el resto para que el tar remoto no se bloquee
+		io.Copy(io.Discard, pr)
+		extracted <- err
+	}()
+
+	cmd := fmt.Sprintf("tar -cf - -C %s %s", shellQuote(dir), shellQuote(base))
+	_, err := vm.runSession(ctx, cmd, nil, pw, io.Discard)
+	pw.Close()
+	if extractErr := <-extracted; err == nil {
+		err = extractErr
+	}
+	if err != nil {
+		return fmt.Errorf("error copiando %s de la VM a %s: %w", remotePath, localPath, err)
+	}
+	return nil
+}
+
+// writeTar escribe en w un tar de localPath cuya raíz se llama root
+func writeTar(w io.Writer, localPath, root string) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.Walk(localPath, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(localPath, file)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(root, filepath.ToSlash(rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		// Los archivos pertenecen al usuario SSH del invitado, no al del host
+		hdr.Uid, hdr.Gid = 0, 0
+		hdr.Uname, hdr.Gname = "", ""
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// readTar extrae en localPath el tar de r cuya raíz se llama root
+func readTar(r io.Reader, root, localPath string) error {
+	tr := tar.NewReader(r)
+
+	type dirAttrs struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+	var dirs []dirAttrs
+	rootLink := false // la raíz copiada es un enlace: no puede tener hijos
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := tarTarget(hdr.Name, root, localPath)
+		if err != nil {
+			return err
+		}
+		// Un enlace creado antes no puede desviar la escritura fuera de localPath
+		if rootLink {
+			return fmt.Errorf("entrada del tar a través del enlace %s", localPath)
+		}
+		if err := checkNoSymlinks(localPath, target); err != nil {
+			return err
+		}
+		mode := os.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			// Los permisos pueden impedir crear los hijos y la fecha cambia al
+			// crearlos: ambos se fijan al final
+			dirs = append(dirs, dirAttrs{target, mode, hdr.ModTime})
+			continue
+
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+			if err := os.Chmod(target, mode); err != nil {
+				return err
+			}
+
+		case tar.TypeSymlink:
+			if err := checkSymlink(localPath, target, hdr.Linkname); err != nil {
+				return err
+			}
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+			rootLink = target == localPath
+			continue
+
+		default:
+			// Dispositivos, fifos...: no se copian
+			continue
+		}
+
+		if err := os.Chtimes(target, hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tarTarget traduce el nombre de una entrada del tar a su ruta en localPath
+// y rechaza las entradas que saldrían de él
+func tarTarget(name, root, localPath string) (string, error) {
+	name = path.Clean(name)
+	if name == root {
+		return localPath, nil
+	}
+
+	rel := strings.TrimPrefix(name, root+"/")
+	if rel == name || rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+		return "", fmt.Errorf("entrada inesperada en el tar: %q", name)
+	}
+	return filepath.Join(localPath, filepath.FromSlash(rel)), nil
+}
+
+// checkSymlink rechaza los enlaces absolutos o que apuntan fuera de
+// localPath. Un enlace en la raíz es la copia de un único enlace y se
+// conserva tal cual: no se escribe nada a través de él.
+func checkSymlink(localPath, target, linkname string) error {
+	if target == localPath {
+		return nil
+	}
+	rel, err := filepath.Rel(localPath, filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	dest := path.Join(filepath.ToSlash(rel), linkname)
+	if path.IsAbs(linkname) || dest == ".." || strings.HasPrefix(dest, "../") {
+		return fmt.Errorf("enlace inseguro en el tar: %s -> %s", target, linkname)
+	}
+	return nil
+}
+
+// checkNoSymlinks devuelve un error si algún directorio entre localPath y
+// target es un enlace simbólico
+func checkNoSymlinks(localPath, target string) error {
+	if target == localPath {
+		return nil
+	}
+	rel, err := filepath.Rel(localPath, filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+
+	if rel == "." {
+		return nil
+	}
+
+	dir := localPath
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		if info, err := os.Lstat(dir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("entrada del tar a través del enlace %s", dir)
+		}
+	}
+	return nil
+}
+
+// shellQuote escapa s para usarlo como un único argumento de sh
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(s, " \t\n'\"\\$`!*?[]{}()<>|&;#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,127 @@
+package goqemu
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyToCopyFrom(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar no está instalado")
+	}
+	vm := dialFakeSSH(t, newFakeSSHServer(t))
+
+	// Árbol local con permisos y fechas conocidas
+	src := filepath.Join(t.TempDir(), "src")
+	os.MkdirAll(filepath.Join(src, "bin"), 0755)
+	os.WriteFile(filepath.Join(src, "app.conf"), []byte("port=8080\n"), 0640)
+	os.WriteFile(filepath.Join(src, "bin", "run.sh"), []byte("#!/bin/sh\necho run\n"), 0755)
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	os.Chtimes(filepath.Join(src, "app.conf"), mtime, mtime)
+
+	// El "invitado" es el propio host de pruebas
+	remote := filepath.Join(t.TempDir(), "deploy", "app")
+	if err := vm.CopyTo(src, remote); err != nil {
+		t.Fatalf("Error en CopyTo: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(remote, "bin", "run.sh")); string(data) != "#!/bin/sh\necho run\n" {
+		t.Errorf("Contenido remoto incorrecto: %q", data)
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := vm.CopyFrom(remote, dst); err != nil {
+		t.Fatalf("Error en CopyFrom: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "app.conf"))
+	if err != nil {
+		t.Fatalf("No se copió app.conf: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Permisos de app.conf %o, se esperaba 640", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("Fecha de app.conf %v, se esperaba %v", info.ModTime(), mtime)
+	}
+	if info, _ := os.Stat(filepath.Join(dst, "bin", "run.sh")); info == nil || info.Mode().Perm() != 0755 {
+		t.Error("No se conservaron los permisos de run.sh")
+	}
+
+	// Un archivo suelto también se copia con el nombre de destino
+	single := filepath.Join(t.TempDir(), "single.conf")
+	if err := vm.CopyFrom(filepath.Join(remote, "app.conf"), single); err != nil {
+		t.Fatalf("Error copiando un archivo: %v", err)
+	}
+	if data, _ := os.ReadFile(single); string(data) != "port=8080\n" {
+		t.Errorf("Contenido incorrecto: %q", data)
+	}
+
+	// Un origen remoto inexistente devuelve el error de tar
+	if err := vm.CopyFrom(filepath.Join(remote, "no-existe"), t.TempDir()); err == nil {
+		t.Error("Copiar un archivo inexistente debería fallar")
+	}
+}
+
+func TestTarTargetRejectsTraversal(t *testing.T) {
+	for _, name := range []string{"app/../../etc/passwd", "otro/archivo", "/etc/passwd"} {
+		if _, err := tarTarget(name, "app", "/tmp/dst"); err == nil {
+			t.Errorf("Se aceptó la entrada %q", name)
+		}
+	}
+	if got, err := tarTarget("app/bin/run.sh", "app", "/tmp/dst"); err != nil || got != filepath.Join("/tmp/dst", "bin", "run.sh") {
+		t.Errorf("Ruta inesperada %q, %v", got, err)
+	}
+}
+
+func TestReadTarRejectsSymlinkEscape(t *testing.T) {
+	outside := t.TempDir()
+
+	archive := func(entries ...*tar.Header) *bytes.Buffer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range entries {
+			tw.WriteHeader(hdr)
+			if hdr.Typeflag == tar.TypeReg {
+				tw.Write(make([]byte, hdr.Size))
+			}
+		}
+		tw.Close()
+		return &buf
+	}
+	dir := &tar.Header{Name: "app/", Typeflag: tar.TypeDir, Mode: 0755}
+	file := func(name string) *tar.Header {
+		return &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 4}
+	}
+	link := func(name, target string) *tar.Header {
+		return &tar.Header{Name: name, Typeflag: tar.TypeSymlink, Linkname: target}
+	}
+
+	for name, buf := range map[string]*bytes.Buffer{
+		"absoluto":      archive(dir, link("app/etc", outside)),
+		"relativo":      archive(dir, link("app/etc", "../../"+filepath.Base(outside))),
+		"a través":      archive(dir, link("app/etc", "."), file("app/etc/passwd")),
+		"raíz enlazada": archive(link("app", outside), file("app/passwd")),
+	} {
+		dst := filepath.Join(t.TempDir(), "app")
+		if err := readTar(buf, "app", dst); err == nil {
+			t.Errorf("%s: se aceptó un enlace que escapa del destino", name)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("Se escribió fuera del destino: %v", entries)
+	}
+
+	// Los enlaces dentro del árbol copiado se conservan
+	dst := filepath.Join(t.TempDir(), "app")
+	if err := readTar(archive(dir, file("app/a.txt"), link("app/b.txt", "a.txt")), "app", dst); err != nil {
+		t.Fatalf("Error con un enlace interno: %v", err)
+	}
+	if got, _ := os.Readlink(filepath.Join(dst, "b.txt")); got != "a.txt" {
+		t.Errorf("Enlace interno inesperado: %q", got)
+	}
+}